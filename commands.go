@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/fixture"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+	"github.com/ricardonunez-io/lumberjack/internal/supervisor"
+	"github.com/rs/zerolog/log"
+)
+
+type backfillLine struct {
+	Monitor  string                   `json:"monitor"`
+	Window   ingestor.AbsoluteRange   `json:"window"`
+	Analysis *analyzer.AnalysisResult `json:"analysis"`
+}
+
+func runOnce(args []string) int {
+	fs, configPath := newFlagSet("once")
+	monitorName := fs.String("monitor", "", "only run the named monitor")
+	flags := addNotifyFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	n, closeOutput, err := flags.notifier()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer closeOutput()
+
+	cfg, monitors, err := loadMonitors(*configPath, *monitorName, flags.options())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	status := exitOK
+	sources := make(map[string]ingestor.LogSource)
+	for _, m := range monitors {
+		aggCfg, err := pullMonitorConfig(ctx, cfg, m, sources)
+		if err != nil {
+			log.Err(err).Str("monitor", m.Name).Msg("Invalid monitor")
+			status = exitError
+			continue
+		}
+		aggCfg.Source = n.wrapSource(aggCfg, aggCfg.Source)
+
+		result, err := aggregator.RunCycle(ctx, aggCfg, aggregator.LatestWindowEnd(aggCfg, time.Now()))
+		if err != nil {
+			log.Err(err).Str("monitor", m.Name).Msg("Aggregation cycle failed")
+			status = exitError
+			continue
+		}
+
+		analysis, err := n.deliver(ctx, result, supervisor.DeliveryFor(m, cfg))
+		if err != nil {
+			log.Err(err).Str("monitor", m.Name).Msg("Error processing aggregation result")
+			status = exitError
+			continue
+		}
+		if analysis.SendSummary && status == exitOK {
+			status = exitAlert
+		}
+	}
+
+	return status
+}
+
+func runBackfill(args []string) int {
+	fs, configPath := newFlagSet("backfill")
+	monitorName := fs.String("monitor", "", "only backfill the named monitor")
+	fromFlag := fs.String("from", "", "start of the range (RFC 3339, required)")
+	toFlag := fs.String("to", "", "end of the range (RFC 3339, default: now)")
+	notify := fs.Bool("notify", false, "send alerting windows to the monitor's Slack destinations")
+	skipAnalysis := fs.Bool("skip-analysis", false, "print aggregation results instead of analyzing them")
+	record := fs.String("record", "", "write each window's source calls, result and analysis to this directory")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	n := &notifier{runAnalysis: analyzeResult}
+	if *record != "" {
+		n.recorder = fixture.NewRecorder(*record)
+	}
+
+	from, to, err := parseRange(*fromFlag, *toFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	cfg, monitors, err := loadMonitors(*configPath, *monitorName, config.Options{DryRun: !*notify})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	out := json.NewEncoder(os.Stdout)
+	status := exitOK
+	sources := make(map[string]ingestor.LogSource)
+	for _, m := range monitors {
+		aggCfg, err := pullMonitorConfig(ctx, cfg, m, sources)
+		if err != nil {
+			log.Err(err).Str("monitor", m.Name).Msg("Invalid monitor")
+			status = exitError
+			continue
+		}
+		// Past windows arrive out of order relative to the live baseline, so
+		// backfills always fetch history directly.
+		aggCfg.Baseline = nil
+		aggCfg.Source = n.wrapSource(aggCfg, aggCfg.Source)
+
+		delivery := supervisor.DeliveryFor(m, cfg)
+		until := to
+		if latest := aggregator.LatestWindowEnd(aggCfg, time.Now()); latest.Before(until) {
+			until = latest
+		}
+		for _, end := range aggregator.WindowEnds(aggCfg, from, until) {
+			if ctx.Err() != nil {
+				return exitError
+			}
+
+			result, err := aggregator.RunCycle(ctx, aggCfg, end)
+			if err != nil {
+				log.Err(err).Str("monitor", m.Name).Time("windowEnd", end).Msg("Aggregation cycle failed")
+				status = exitError
+				continue
+			}
+			if *skipAnalysis {
+				n.record(result, nil)
+				if err := out.Encode(result); err != nil {
+					log.Err(err).Str("monitor", m.Name).Msg("Failed to write aggregation result")
+					status = exitError
+				}
+				continue
+			}
+
+			analysis, err := n.handle(ctx, result, delivery, *notify)
+			if err != nil {
+				log.Err(err).Str("monitor", m.Name).Time("windowEnd", end).Msg("Error processing aggregation result")
+				status = exitError
+				continue
+			}
+			out.Encode(backfillLine{Monitor: m.Name, Window: result.Window, Analysis: analysis})
+			if analysis.SendSummary && status == exitOK {
+				status = exitAlert
+			}
+		}
+	}
+
+	return status
+}
+
+func runReplay(args []string) int {
+	fs, configPath := newFlagSet("replay")
+	notify := fs.Bool("notify", false, "send alerting analyses to the monitor's Slack destinations")
+	liveAnalysis := fs.Bool("live-analysis", false, "with fixtures, call the analyzer instead of reusing the recorded analysis")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: lumberjack replay [flags] <results.json | fixture directory>")
+		return exitError
+	}
+	path := fs.Arg(0)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if info.IsDir() {
+		return replayFixtures(ctx, path, *configPath, *notify, *liveAnalysis)
+	}
+	return replayResults(ctx, path, *configPath, *notify)
+}
+
+func replayResults(ctx context.Context, path, configPath string, notify bool) int {
+	deliveries, err := loadDeliveries(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer f.Close()
+
+	n := &notifier{runAnalysis: analyzeResult}
+	out := json.NewEncoder(os.Stdout)
+	status := exitOK
+	// The file may hold one result or a stream of them, as written by
+	// backfill --skip-analysis.
+	dec := json.NewDecoder(f)
+	for {
+		var result aggregator.AggregationResult
+		if err := dec.Decode(&result); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return exitError
+		}
+
+		analysis, err := n.handle(ctx, result, deliveries(result.Monitor), notify)
+		if err != nil {
+			log.Err(err).Str("monitor", result.Monitor).Msg("Error processing aggregation result")
+			status = exitError
+			continue
+		}
+		out.Encode(analysis)
+		if analysis.SendSummary && status == exitOK {
+			status = exitAlert
+		}
+	}
+
+	return status
+}
+
+type fixtureLine struct {
+	Fixture  string                   `json:"fixture"`
+	Monitor  string                   `json:"monitor"`
+	Window   ingestor.AbsoluteRange   `json:"window"`
+	Matches  bool                     `json:"matches"`
+	Analysis *analyzer.AnalysisResult `json:"analysis"`
+}
+
+// replayFixtures reruns every recorded cycle under dir against its recorded
+// source calls and, unless liveAnalysis is set, its recorded analysis, so
+// nothing is fetched from the network apart from notifications.
+func replayFixtures(ctx context.Context, dir, configPath string, notify, liveAnalysis bool) int {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && fixture.IsCycle(path) {
+			paths = append(paths, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "no recorded cycles found in %s\n", dir)
+		return exitError
+	}
+
+	// Configuration is only needed to reach the analyzer or Slack.
+	deliveries := func(string) supervisor.Delivery { return supervisor.Delivery{} }
+	if notify || liveAnalysis {
+		if deliveries, err = loadDeliveries(configPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	status := exitOK
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return exitError
+		}
+
+		c, err := fixture.Load(path)
+		if err != nil {
+			log.Err(err).Str("fixture", path).Msg("Failed to load fixture")
+			status = exitError
+			continue
+		}
+
+		result, err := fixture.Replay(ctx, c)
+		if err != nil {
+			log.Err(err).Str("fixture", path).Msg("Aggregation cycle failed")
+			status = exitError
+			continue
+		}
+		matches, err := sameJSON(result, c.Result)
+		if err != nil {
+			log.Err(err).Str("fixture", path).Msg("Failed to compare aggregation results")
+			status = exitError
+			continue
+		}
+		if !matches {
+			log.Warn().Str("fixture", path).Msg("Replayed aggregation result differs from the recording")
+			status = exitError
+		}
+
+		n := &notifier{runAnalysis: analyzeResult}
+		if !liveAnalysis {
+			if c.Analysis == nil {
+				out.Encode(fixtureLine{Fixture: path, Monitor: result.Monitor, Window: result.Window, Matches: matches})
+				continue
+			}
+			n.runAnalysis = recordedAnalysis(c.Analysis)
+		}
+
+		analysis, err := n.handle(ctx, result, deliveries(result.Monitor), notify)
+		if err != nil {
+			log.Err(err).Str("fixture", path).Msg("Error processing aggregation result")
+			status = exitError
+			continue
+		}
+		out.Encode(fixtureLine{Fixture: path, Monitor: result.Monitor, Window: result.Window, Matches: matches, Analysis: analysis})
+		if analysis.SendSummary && status == exitOK {
+			status = exitAlert
+		}
+	}
+
+	return status
+}
+
+// recordedAnalysis returns an analyzer stand-in that answers with analysis.
+func recordedAnalysis(analysis *analyzer.AnalysisResult) func(context.Context, aggregator.AggregationResult, analyzer.Config) (*analyzer.AnalysisResult, error) {
+	return func(context.Context, aggregator.AggregationResult, analyzer.Config) (*analyzer.AnalysisResult, error) {
+		a := *analysis
+		return &a, nil
+	}
+}
+
+func sameJSON(a, b any) (bool, error) {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(x, y), nil
+}
+
+// loadDeliveries returns a lookup of each configured monitor's delivery
+// settings by name.
+func loadDeliveries(configPath string) (func(string) supervisor.Delivery, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("configuration is invalid:\n%w", err)
+	}
+
+	monitors := make(map[string]config.Monitor)
+	for _, m := range cfg.ResolvedMonitors() {
+		monitors[m.Name] = m
+	}
+	return func(name string) supervisor.Delivery {
+		return supervisor.DeliveryFor(monitors[name], cfg)
+	}, nil
+}
+
+func runSchema(args []string) int {
+	fs, configPath := newFlagSet("schema")
+	monitorName := fs.String("monitor", "", "monitor whose source and query to inspect (default: the first monitor)")
+	query := fs.String("query", "", "override the monitor's query")
+	last := fs.Duration("last", 0, "how far back to sample logs (default: the monitor's time interval)")
+	sampleSize := fs.Int("sample", 0, "logs sampled for discovery (default: schema.sampleSize)")
+	asJSON := fs.Bool("json", false, "print the schema as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	cfg, monitors, err := loadMonitors(*configPath, *monitorName, config.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	m := monitors[0]
+	if *query != "" {
+		m.Query = *query
+	}
+	if *sampleSize <= 0 {
+		*sampleSize = cfg.Schema.SampleSize
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	aggCfg, err := pullMonitorConfig(ctx, cfg, m, make(map[string]ingestor.LogSource))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *last <= 0 {
+		*last = aggCfg.TimeInterval
+	}
+
+	end := time.Now().Add(-aggCfg.IngestionDelay)
+	logs, err := ingestor.IngestWithinTimeRange(ctx, ingestor.NewAbsoluteRange(end.Add(-*last), end), aggCfg.Source, m.Query)
+	if err != nil && !errors.Is(err, ingestor.ErrTruncated) {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	s := schema.DiscoverWithSampleSize(logs, *sampleSize)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(s)
+		return exitOK
+	}
+
+	fmt.Printf("%d logs fetched for %q over the last %s, %d sampled\n\n", len(logs), m.Query, *last, min(len(logs), *sampleSize))
+	selected := make(map[string]bool, len(m.Dimensions))
+	for _, d := range m.Dimensions {
+		selected[d] = true
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tTYPE\tCARDINALITY\tDIMENSION\tEXAMPLES")
+	for _, f := range s.Fields {
+		dimension := ""
+		if selected[f.Name] {
+			dimension = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", f.Name, f.Type, f.Cardinality, dimension, strings.Join(f.Examples, ", "))
+	}
+	w.Flush()
+
+	for _, d := range m.Dimensions {
+		if !s.HasField(d) {
+			fmt.Printf("\ndimension %q was not found in the sampled logs\n", d)
+		}
+	}
+	return exitOK
+}
+
+func loadMonitors(path, name string, opts config.Options) (config.Config, []config.Monitor, error) {
+	cfg, err := config.LoadWith(path, opts)
+	if err != nil {
+		return config.Config{}, nil, fmt.Errorf("configuration is invalid:\n%w", err)
+	}
+
+	monitors := cfg.ResolvedMonitors()
+	if name == "" {
+		return cfg, monitors, nil
+	}
+	for _, m := range monitors {
+		if m.Name == name {
+			return cfg, []config.Monitor{m}, nil
+		}
+	}
+	return config.Config{}, nil, fmt.Errorf("no monitor named %q", name)
+}
+
+// pullMonitorConfig builds the aggregation config for a one-off command,
+// sharing sources between monitors. Push receivers are rejected since they
+// only hold logs received while the daemon runs.
+func pullMonitorConfig(ctx context.Context, cfg config.Config, m config.Monitor, sources map[string]ingestor.LogSource) (aggregator.AggregationConfig, error) {
+	if m.Source == "otlp" || m.Source == "syslog" {
+		return aggregator.AggregationConfig{}, fmt.Errorf("the %s source only receives logs while lumberjack runs as a daemon", m.Source)
+	}
+
+	aggCfg, err := supervisor.MonitorConfig(m, cfg)
+	if err != nil {
+		return aggregator.AggregationConfig{}, err
+	}
+
+	source, ok := sources[m.Source]
+	if !ok {
+		source, err = buildLogSource(ctx, cfg.Sources, m.Source, 0)
+		if err != nil {
+			return aggregator.AggregationConfig{}, err
+		}
+		sources[m.Source] = source
+	}
+	aggCfg.Source = source
+	return aggCfg, nil
+}
+
+func parseRange(fromFlag, toFlag string) (time.Time, time.Time, error) {
+	if fromFlag == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from is required")
+	}
+	from, err := time.Parse(time.RFC3339, fromFlag)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from: %w", err)
+	}
+
+	to := time.Now()
+	if toFlag != "" {
+		if to, err = time.Parse(time.RFC3339, toFlag); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--to: %w", err)
+		}
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--to must be after --from")
+	}
+	return from, to, nil
+}
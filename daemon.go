@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/leader"
+	"github.com/ricardonunez-io/lumberjack/internal/outbox"
+	"github.com/ricardonunez-io/lumberjack/internal/supervisor"
+	"github.com/rs/zerolog/log"
+)
+
+// daemon runs the monitors for as long as this instance leads, with a new
+// supervisor for each term. Without leader election there is one term,
+// which ends at shutdown.
+type daemon struct {
+	ctx    context.Context
+	n      *notifier
+	outbox *outbox.Outbox
+	grace  time.Duration
+	force  <-chan struct{}
+
+	// stateCfg is set when each term opens the state store, so a standby
+	// that takes over starts from what the previous leader recorded. The
+	// store keeps no files open, so the previous term's is simply dropped.
+	stateCfg *config.StateConfig
+
+	mu      sync.Mutex
+	cfg     config.Config
+	sup     *supervisor.Supervisor
+	dropped int
+}
+
+// newElector returns nil when leader election is disabled.
+func newElector(cfg config.Config) (*leader.Elector, error) {
+	if cfg.Leader.Backend == leader.BackendNone {
+		return nil, nil
+	}
+
+	ttl, err := time.ParseDuration(cfg.Leader.TTL)
+	if err != nil {
+		return nil, err
+	}
+	holder := cfg.Leader.Holder
+	if holder == "" {
+		holder = leader.DefaultHolder()
+	}
+	return &leader.Elector{Lease: leader.NewFileLease(cfg.Leader.Path), Holder: holder, TTL: ttl}, nil
+}
+
+// lead runs the monitors until ctx is done. At shutdown, results already
+// produced get the grace period to be delivered and the rest go to the
+// outbox. A term that ends because the lease was lost delivers nothing
+// more: the new leader runs those windows again. An error starting the term
+// is returned before any monitor runs.
+func (d *daemon) lead(ctx context.Context) error {
+	if d.stateCfg != nil {
+		store, err := openState(*d.stateCfg)
+		if err != nil {
+			return fmt.Errorf("failed to open state store: %w", err)
+		}
+		d.n.state = store
+	}
+
+	d.mu.Lock()
+	sup := supervisor.New(buildLogSource, d.n.deliver)
+	sup.WrapSource = d.n.wrapSource
+	sup.State = d.n.state
+	if err := sup.Start(ctx, d.cfg); err != nil {
+		d.mu.Unlock()
+		return fmt.Errorf("failed to start monitors: %w", err)
+	}
+	d.sup = sup
+	d.mu.Unlock()
+
+	if d.outbox != nil {
+		go redeliver(d.outbox, sup)
+	}
+
+	<-ctx.Done()
+	defer func() {
+		d.mu.Lock()
+		d.sup = nil
+		d.mu.Unlock()
+	}()
+
+	if d.ctx.Err() == nil {
+		lost, cancel := context.WithCancel(context.Background())
+		cancel()
+		if n := len(sup.Shutdown(lost)); n > 0 {
+			log.Warn().Int("results", n).Msg("Discarding undelivered results after losing the leader lease")
+		}
+		return nil
+	}
+
+	// No new cycles are scheduled from here; results already produced get
+	// the grace period to be delivered, or a second signal cuts it short.
+	log.Info().Dur("gracePeriod", d.grace).Msg("Waiting for deliveries in flight")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), d.grace)
+	defer cancelShutdown()
+	go func() {
+		select {
+		case <-d.force:
+			cancelShutdown()
+		case <-shutdownCtx.Done():
+		}
+	}()
+
+	dropped := saveUndelivered(d.outbox, sup.Shutdown(shutdownCtx))
+	d.mu.Lock()
+	d.dropped += dropped
+	d.mu.Unlock()
+	return nil
+}
+
+// reload applies next to the running monitors, if this instance leads, and
+// to every later term.
+func (d *daemon) reload(next config.Config) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sup != nil {
+		if err := d.sup.Reload(next); err != nil {
+			return err
+		}
+	}
+	d.cfg = next
+	return nil
+}
+
+func (d *daemon) current() *supervisor.Supervisor {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sup
+}
+
+func (d *daemon) Status() []supervisor.MonitorStatus {
+	sup := d.current()
+	if sup == nil {
+		return nil
+	}
+	return sup.Status()
+}
+
+func (d *daemon) Trigger(name string) ([]string, error) {
+	sup := d.current()
+	if sup == nil {
+		return nil, leader.ErrNotLeader
+	}
+	return sup.Trigger(name)
+}
+
+func (d *daemon) role() string {
+	if d.current() == nil {
+		return leader.RoleStandby
+	}
+	return leader.RoleLeader
+}
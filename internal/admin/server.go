@@ -0,0 +1,165 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/leader"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
+	"github.com/ricardonunez-io/lumberjack/internal/supervisor"
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultReadyIntervals = 3
+
+// Monitors is the view of the running monitors the server reports on and
+// triggers.
+type Monitors interface {
+	Status() []supervisor.MonitorStatus
+	Trigger(name string) ([]string, error)
+}
+
+// Server serves health, readiness, status and Prometheus metrics endpoints
+// for orchestrators, and lets operators trigger a cycle without waiting for
+// the schedule.
+type Server struct {
+	// Role reports leader.RoleLeader or leader.RoleStandby when leader
+	// election is enabled. A standby runs no monitors and is ready as such.
+	Role func() string
+
+	monitors       Monitors
+	readyIntervals int
+	now            func() time.Time
+	mux            *http.ServeMux
+}
+
+type monitorStatus struct {
+	supervisor.MonitorStatus
+	Ready bool `json:"ready"`
+}
+
+type statusResponse struct {
+	Role     string          `json:"role,omitempty"`
+	Ready    bool            `json:"ready"`
+	Monitors []monitorStatus `json:"monitors"`
+}
+
+// New returns a server that reports a monitor ready while it is within
+// readyIntervals of its schedule; see supervisor.MonitorStatus.Ready.
+func New(monitors Monitors, readyIntervals int) *Server {
+	if readyIntervals <= 0 {
+		readyIntervals = DefaultReadyIntervals
+	}
+	s := &Server{
+		monitors:       monitors,
+		readyIntervals: readyIntervals,
+		now:            time.Now,
+		mux:            http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /readyz", s.readyz)
+	s.mux.HandleFunc("GET /status", s.status)
+	s.mux.HandleFunc("POST /run", s.run)
+	s.mux.Handle("GET /metrics", metrics.Handler())
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(w, req)
+}
+
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("addr", addr).Msg("Starting admin server")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) healthz(w http.ResponseWriter, req *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+func (s *Server) readyz(w http.ResponseWriter, req *http.Request) {
+	resp := s.snapshot()
+	notReady := []string{}
+	for _, m := range resp.Monitors {
+		if !m.Ready {
+			notReady = append(notReady, m.Name)
+		}
+	}
+
+	code := http.StatusOK
+	if !resp.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"ready": resp.Ready, "notReady": notReady})
+}
+
+func (s *Server) status(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, s.snapshot())
+}
+
+// run triggers the monitor named by the monitor query parameter, or every
+// monitor without one.
+func (s *Server) run(w http.ResponseWriter, req *http.Request) {
+	triggered, err := s.monitors.Trigger(req.URL.Query().Get("monitor"))
+	if errors.Is(err, supervisor.ErrUnknownMonitor) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, leader.ErrNotLeader) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Strs("monitors", triggered).Msg("Aggregation cycle triggered over the admin API")
+	writeJSON(w, http.StatusAccepted, map[string]any{"triggered": triggered})
+}
+
+// snapshot is ready only when there is at least one monitor and every
+// monitor is ready, so a process that has not started its loops yet is not.
+// A standby is always ready, so an orchestrator keeps it around to take over.
+func (s *Server) snapshot() statusResponse {
+	now := s.now()
+	statuses := s.monitors.Status()
+	resp := statusResponse{Ready: len(statuses) > 0, Monitors: make([]monitorStatus, len(statuses))}
+	if s.Role != nil {
+		resp.Role = s.Role()
+		resp.Ready = resp.Ready || resp.Role == leader.RoleStandby
+	}
+	for i, st := range statuses {
+		ready := st.Ready(now, s.readyIntervals)
+		resp.Monitors[i] = monitorStatus{MonitorStatus: st, Ready: ready}
+		resp.Ready = resp.Ready && ready
+	}
+	return resp
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
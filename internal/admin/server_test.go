@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/leader"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
+	"github.com/ricardonunez-io/lumberjack/internal/supervisor"
+)
+
+type fakeMonitors struct {
+	statuses   []supervisor.MonitorStatus
+	triggered  []string
+	triggerErr error
+}
+
+func (f *fakeMonitors) Status() []supervisor.MonitorStatus {
+	return f.statuses
+}
+
+func (f *fakeMonitors) Trigger(name string) ([]string, error) {
+	if f.triggerErr != nil {
+		return nil, f.triggerErr
+	}
+	if name == "" {
+		for _, st := range f.statuses {
+			f.triggered = append(f.triggered, st.Name)
+		}
+		return f.triggered, nil
+	}
+	for _, st := range f.statuses {
+		if st.Name == name {
+			f.triggered = append(f.triggered, name)
+			return []string{name}, nil
+		}
+	}
+	return nil, supervisor.ErrUnknownMonitor
+}
+
+func serve(t *testing.T, s *Server, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestServer_Readiness(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	healthy := supervisor.MonitorStatus{
+		Name:         "api",
+		TimeInterval: 15 * time.Minute,
+		StartedAt:    now.Add(-24 * time.Hour),
+		NextRun:      now.Add(5 * time.Minute),
+		LastSuccess:  now.Add(-10 * time.Minute),
+	}
+	stuck := healthy
+	stuck.Name, stuck.NextRun = "web", now.Add(-time.Hour)
+
+	monitors := &fakeMonitors{statuses: []supervisor.MonitorStatus{healthy}}
+	s := New(monitors, 3)
+	s.now = func() time.Time { return now }
+
+	if rec := serve(t, s, http.MethodGet, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("healthz: got %d", rec.Code)
+	}
+	if rec := serve(t, s, http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("readyz with a healthy monitor: got %d", rec.Code)
+	}
+
+	monitors.statuses = append(monitors.statuses, stuck)
+	rec := serve(t, s, http.MethodGet, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz with a stuck monitor: got %d", rec.Code)
+	}
+	var body struct {
+		NotReady []string `json:"notReady"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.NotReady) != 1 || body.NotReady[0] != "web" {
+		t.Errorf("readyz body: got %s", rec.Body)
+	}
+
+	monitors.statuses = nil
+	if rec := serve(t, s, http.MethodGet, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz before any monitor started: got %d", rec.Code)
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	now := time.Now()
+	monitors := &fakeMonitors{statuses: []supervisor.MonitorStatus{{
+		Name:         "api",
+		TimeInterval: 15 * time.Minute,
+		StartedAt:    now,
+		LastLogCount: 42,
+	}}}
+	rec := serve(t, New(monitors, 3), http.MethodGet, "/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d", rec.Code)
+	}
+
+	var body statusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if !body.Ready || len(body.Monitors) != 1 || body.Monitors[0].Name != "api" ||
+		body.Monitors[0].LastLogCount != 42 || !body.Monitors[0].Ready {
+		t.Errorf("status body: got %s", rec.Body)
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	monitors := &fakeMonitors{statuses: []supervisor.MonitorStatus{{Name: "api"}, {Name: "web"}}}
+	s := New(monitors, 3)
+
+	if rec := serve(t, s, http.MethodPost, "/run?monitor=api"); rec.Code != http.StatusAccepted {
+		t.Errorf("run api: got %d", rec.Code)
+	}
+	if rec := serve(t, s, http.MethodPost, "/run?monitor=jobs"); rec.Code != http.StatusNotFound {
+		t.Errorf("run unknown: got %d", rec.Code)
+	}
+	if rec := serve(t, s, http.MethodGet, "/run"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET run: got %d", rec.Code)
+	}
+	if rec := serve(t, s, http.MethodPost, "/run"); rec.Code != http.StatusAccepted {
+		t.Errorf("run all: got %d", rec.Code)
+	}
+	if want := []string{"api", "api", "web"}; len(monitors.triggered) != len(want) {
+		t.Errorf("triggered: got %v, want %v", monitors.triggered, want)
+	}
+}
+
+func TestServer_Standby(t *testing.T) {
+	monitors := &fakeMonitors{triggerErr: leader.ErrNotLeader}
+	s := New(monitors, 3)
+	role := leader.RoleStandby
+	s.Role = func() string { return role }
+
+	if rec := serve(t, s, http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("readyz on a standby: got %d", rec.Code)
+	}
+	if rec := serve(t, s, http.MethodPost, "/run"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("run on a standby: got %d", rec.Code)
+	}
+
+	role = leader.RoleLeader
+	rec := serve(t, s, http.MethodGet, "/status")
+	var body statusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Role != leader.RoleLeader || body.Ready {
+		t.Errorf("status of a leader without monitors: got %s", rec.Body)
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	metrics.Cycles.WithLabelValues("api", "success").Inc()
+
+	rec := serve(t, New(&fakeMonitors{}, 3), http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metrics: got %d", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `lumberjack_cycles_total{monitor="api",outcome="success"}`) {
+		t.Errorf("metrics body is missing the cycle counter:\n%s", body)
+	}
+}
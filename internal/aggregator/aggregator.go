@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
 	"github.com/ricardonunez-io/lumberjack/internal/fuzzy"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 	"github.com/ricardonunez-io/lumberjack/internal/schema"
 	"github.com/rs/zerolog/log"
 )
@@ -20,7 +20,19 @@ type DimensionData struct {
 	messages      []string
 }
 
-func Aggregate(responses []datadogV2.Log, s schema.Schema, logSeverity string) Aggregates {
+func Aggregate(records []ingestor.LogRecord, s schema.Schema, logSeverity string) Aggregates {
+	return AggregateWithThreshold(records, s, logSeverity, fuzzy.DefaultSimilarityThreshold)
+}
+
+func AggregateWithThreshold(records []ingestor.LogRecord, s schema.Schema, logSeverity string, threshold float64) Aggregates {
+	agg := countDimensions(records, s, logSeverity)
+	groupMessages(agg, threshold)
+	return agg
+}
+
+// countDimensions counts each dimension's values and collects the messages
+// that groupMessages later groups.
+func countDimensions(records []ingestor.LogRecord, s schema.Schema, logSeverity string) Aggregates {
 	agg := Aggregates{
 		Dimensions: make(map[string]*DimensionData),
 	}
@@ -31,21 +43,14 @@ func Aggregate(responses []datadogV2.Log, s schema.Schema, logSeverity string) A
 		}
 	}
 
-	for _, ddLog := range responses {
-		if ddLog.Attributes == nil {
+	for _, rec := range records {
+		if rec.Status != "" && ShouldSkipLog(rec.Status, logSeverity) {
+			log.Debug().Str("id", rec.ID).Msg("Skipping log due to severity filter")
 			continue
 		}
 
-		if ddLog.Attributes.Status != nil && ShouldSkipLog(*ddLog.Attributes.Status, logSeverity) {
-			log.Debug().Str("id", stringId(ddLog.Id)).Msg("Skipping log due to severity filter")
-			continue
-		}
-
-		values := extractFieldValues(ddLog, s)
-		var msg string
-		if ddLog.Attributes.Message != nil {
-			msg = *ddLog.Attributes.Message
-		}
+		values := extractFieldValues(rec, s)
+		msg := rec.Message
 
 		for fieldName, value := range values {
 			dim, ok := agg.Dimensions[fieldName]
@@ -59,39 +64,37 @@ func Aggregate(responses []datadogV2.Log, s schema.Schema, logSeverity string) A
 		}
 	}
 
+	return agg
+}
+
+func groupMessages(agg Aggregates, threshold float64) {
 	for _, dim := range agg.Dimensions {
 		if len(dim.messages) > 0 {
-			dim.MessageGroups = fuzzy.Group(dim.messages)
+			dim.MessageGroups = fuzzy.GroupWithThreshold(dim.messages, threshold)
 		}
 		dim.messages = nil
 	}
-
-	return agg
 }
 
-func extractFieldValues(l datadogV2.Log, s schema.Schema) map[string]string {
+func extractFieldValues(r ingestor.LogRecord, s schema.Schema) map[string]string {
 	values := make(map[string]string)
 
-	if l.Attributes == nil {
-		return values
-	}
-
 	for _, f := range s.Fields {
 		switch f.Name {
 		case "status":
-			if l.Attributes.Status != nil {
-				values[f.Name] = strings.ToLower(*l.Attributes.Status)
+			if r.Status != "" {
+				values[f.Name] = strings.ToLower(r.Status)
 			}
 		case "host":
-			if l.Attributes.Host != nil {
-				values[f.Name] = *l.Attributes.Host
+			if r.Host != "" {
+				values[f.Name] = r.Host
 			}
 		case "service":
-			if l.Attributes.Service != nil {
-				values[f.Name] = *l.Attributes.Service
+			if r.Service != "" {
+				values[f.Name] = r.Service
 			}
 		default:
-			val := getNestedValue(l.Attributes.Attributes, f.Name)
+			val := getNestedValue(r.Attributes, f.Name)
 			if val != "" {
 				values[f.Name] = val
 			}
@@ -123,10 +126,3 @@ func getNestedValue(attrs interface{}, key string) string {
 
 	return getNestedValue(val, parts[1])
 }
-
-func stringId(id *string) string {
-	if id == nil {
-		return "<nil>"
-	}
-	return *id
-}
@@ -2,15 +2,11 @@ package aggregator
 
 import (
 	"testing"
-	"time"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 	"github.com/ricardonunez-io/lumberjack/internal/schema"
 )
 
-func strPtr(s string) *string        { return &s }
-func timePtr(t time.Time) *time.Time { return &t }
-
 func testSchema() schema.Schema {
 	return schema.Schema{
 		Fields: []schema.Field{
@@ -21,39 +17,31 @@ func testSchema() schema.Schema {
 	}
 }
 
-func makeLogs() []datadogV2.Log {
-	return []datadogV2.Log{
+func makeLogs() []ingestor.LogRecord {
+	return []ingestor.LogRecord{
 		{
-			Attributes: &datadogV2.LogAttributes{
-				Status:  strPtr("error"),
-				Host:    strPtr("web-01"),
-				Service: strPtr("api"),
-				Message: strPtr("connection timeout"),
-			},
+			Status:  "error",
+			Host:    "web-01",
+			Service: "api",
+			Message: "connection timeout",
 		},
 		{
-			Attributes: &datadogV2.LogAttributes{
-				Status:  strPtr("error"),
-				Host:    strPtr("web-02"),
-				Service: strPtr("api"),
-				Message: strPtr("connection timeout"),
-			},
+			Status:  "error",
+			Host:    "web-02",
+			Service: "api",
+			Message: "connection timeout",
 		},
 		{
-			Attributes: &datadogV2.LogAttributes{
-				Status:  strPtr("warning"),
-				Host:    strPtr("web-01"),
-				Service: strPtr("worker"),
-				Message: strPtr("high memory usage"),
-			},
+			Status:  "warning",
+			Host:    "web-01",
+			Service: "worker",
+			Message: "high memory usage",
 		},
 		{
-			Attributes: &datadogV2.LogAttributes{
-				Status:  strPtr("info"),
-				Host:    strPtr("web-01"),
-				Service: strPtr("api"),
-				Message: strPtr("request completed"),
-			},
+			Status:  "info",
+			Host:    "web-01",
+			Service: "api",
+			Message: "request completed",
 		},
 	}
 }
@@ -188,9 +176,9 @@ func TestAggregate_EmptyLogs(t *testing.T) {
 }
 
 func TestAggregate_NilAttributes(t *testing.T) {
-	logs := []datadogV2.Log{
+	logs := []ingestor.LogRecord{
 		{Attributes: nil},
-		{Attributes: &datadogV2.LogAttributes{Status: strPtr("error"), Host: strPtr("h"), Service: strPtr("s"), Message: strPtr("msg")}},
+		{Status: "error", Host: "h", Service: "s", Message: "msg"},
 	}
 	s := testSchema()
 	agg := Aggregate(logs, s, "ALL")
@@ -201,14 +189,12 @@ func TestAggregate_NilAttributes(t *testing.T) {
 }
 
 func TestAggregate_DynamicSchema(t *testing.T) {
-	logs := []datadogV2.Log{
+	logs := []ingestor.LogRecord{
 		{
-			Attributes: &datadogV2.LogAttributes{
-				Status:  strPtr("error"),
-				Message: strPtr("test"),
-				Attributes: map[string]interface{}{
-					"env": "production",
-				},
+			Status:  "error",
+			Message: "test",
+			Attributes: map[string]interface{}{
+				"env": "production",
 			},
 		},
 	}
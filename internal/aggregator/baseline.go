@@ -0,0 +1,279 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+	"github.com/rs/zerolog/log"
+)
+
+type BaselineBucket struct {
+	Start      time.Time               `json:"start"`
+	Dimensions map[string]IntervalData `json:"dimensions"`
+	Truncated  bool                    `json:"truncated,omitempty"`
+}
+
+type baselineSnapshot struct {
+	Interval time.Duration    `json:"interval"`
+	Window   time.Duration    `json:"window"`
+	Fields   []string         `json:"fields"`
+	Buckets  []BaselineBucket `json:"buckets"`
+}
+
+type Baseline struct {
+	mu       sync.Mutex
+	interval time.Duration
+	window   time.Duration
+	path     string
+	fields   []string
+	buckets  []BaselineBucket
+}
+
+func NewBaseline(interval, window time.Duration, path string) *Baseline {
+	b := &Baseline{
+		interval: interval,
+		window:   window,
+		path:     path,
+	}
+	if path == "" {
+		return b
+	}
+
+	if err := b.load(); err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Ignoring historical baseline snapshot")
+		b.fields, b.buckets = nil, nil
+	}
+	return b
+}
+
+func (b *Baseline) load() error {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap baselineSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Interval != b.interval || snap.Window != b.window {
+		return fmt.Errorf("snapshot was taken with interval %s and window %s", snap.Interval, snap.Window)
+	}
+
+	for i, bucket := range snap.Buckets {
+		for name, data := range bucket.Dimensions {
+			if data.Count > 0 && data.Values == nil {
+				return fmt.Errorf("snapshot has no value counts for %s, it predates them", name)
+			}
+		}
+		snap.Buckets[i].Start = snap.Buckets[i].Start.UTC()
+	}
+	b.fields = snap.Fields
+	b.buckets = snap.Buckets
+	log.Info().
+		Int("buckets", len(b.buckets)).
+		Str("path", b.path).
+		Msg("Loaded historical baseline snapshot")
+	return nil
+}
+
+func (b *Baseline) Save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	data, err := json.Marshal(baselineSnapshot{
+		Interval: b.interval,
+		Window:   b.window,
+		Fields:   b.fields,
+		Buckets:  b.buckets,
+	})
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.path)
+}
+
+func (b *Baseline) Matches(s schema.Schema) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fields == nil || slices.Equal(b.fields, sortedFieldNames(s))
+}
+
+func (b *Baseline) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fields = nil
+	b.buckets = nil
+}
+
+func (b *Baseline) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buckets)
+}
+
+// Gaps evicts buckets that have aged out of the window ending at end and
+// returns the missing ranges, coalescing adjacent missing buckets.
+func (b *Baseline) Gaps(end time.Time) []ingestor.TimeRange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	end = end.UTC().Truncate(b.interval)
+	start := end.Add(-b.window).Truncate(b.interval)
+
+	kept := b.buckets[:0]
+	for _, bucket := range b.buckets {
+		if !bucket.Start.Before(start) && bucket.Start.Before(end) {
+			kept = append(kept, bucket)
+		}
+	}
+	b.buckets = kept
+
+	present := make(map[time.Time]bool, len(b.buckets))
+	for _, bucket := range b.buckets {
+		present[bucket.Start] = true
+	}
+
+	var missing []ingestor.AbsoluteRange
+	for t := start; t.Before(end); t = t.Add(b.interval) {
+		if present[t] {
+			continue
+		}
+		if n := len(missing); n > 0 && missing[n-1].To.Equal(t) {
+			missing[n-1].To = t.Add(b.interval)
+			continue
+		}
+		missing = append(missing, ingestor.NewAbsoluteRange(t, t.Add(b.interval)))
+	}
+
+	gaps := make([]ingestor.TimeRange, len(missing))
+	for i, r := range missing {
+		gaps[i] = r
+	}
+	return gaps
+}
+
+func (b *Baseline) Fill(gaps []ingestor.TimeRange, records []ingestor.LogRecord, s schema.Schema, logSeverity string, truncated bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	filled := make(map[time.Time]*BaselineBucket)
+	for _, gap := range gaps {
+		for t := gap.Start().UTC(); t.Before(gap.End()); t = t.Add(b.interval) {
+			bucket := &BaselineBucket{
+				Start:      t,
+				Dimensions: make(map[string]IntervalData),
+				Truncated:  truncated,
+			}
+			for _, f := range s.Fields {
+				bucket.Dimensions[f.Name] = newIntervalData()
+			}
+			filled[t] = bucket
+		}
+	}
+
+	for _, rec := range records {
+		if rec.Timestamp.IsZero() {
+			continue
+		}
+		if rec.Status != "" && ShouldSkipLog(rec.Status, logSeverity) {
+			continue
+		}
+
+		bucket, ok := filled[rec.Timestamp.UTC().Truncate(b.interval)]
+		if !ok {
+			continue
+		}
+
+		for fieldName, value := range extractFieldValues(rec, s) {
+			data, ok := bucket.Dimensions[fieldName]
+			if !ok {
+				continue
+			}
+			data.Count++
+			data.Values[value]++
+			if rec.Message != "" {
+				data.Messages[rec.Message]++
+			}
+			bucket.Dimensions[fieldName] = data
+		}
+	}
+
+	kept := b.buckets[:0]
+	for _, bucket := range b.buckets {
+		if _, replaced := filled[bucket.Start]; !replaced {
+			kept = append(kept, bucket)
+		}
+	}
+	b.buckets = kept
+	for _, bucket := range filled {
+		b.buckets = append(b.buckets, *bucket)
+	}
+	sort.Slice(b.buckets, func(i, j int) bool {
+		return b.buckets[i].Start.Before(b.buckets[j].Start)
+	})
+	b.fields = sortedFieldNames(s)
+}
+
+func (b *Baseline) Historical(s schema.Schema) (HistoricalAggregates, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := HistoricalAggregates{
+		Dimensions: make(map[string]*HistoricalDimensionData),
+	}
+	for _, f := range s.Fields {
+		hd := &HistoricalDimensionData{
+			Intervals: make([]IntervalData, len(b.buckets)),
+		}
+		for i, bucket := range b.buckets {
+			data, ok := bucket.Dimensions[f.Name]
+			if !ok {
+				data = newIntervalData()
+			}
+			hd.Intervals[i] = data
+		}
+		result.Dimensions[f.Name] = hd
+	}
+
+	truncated := false
+	for _, bucket := range b.buckets {
+		truncated = truncated || bucket.Truncated
+	}
+	return result, truncated
+}
+
+func sortedFieldNames(s schema.Schema) []string {
+	names := s.FieldNames()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,119 @@
+package aggregator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+)
+
+func TestBaseline_GapsCoalesceMissingBuckets(t *testing.T) {
+	b := NewBaseline(15*time.Minute, time.Hour, "")
+	end := time.Date(2024, 1, 1, 12, 7, 0, 0, time.UTC)
+
+	gaps := b.Gaps(end)
+	if len(gaps) != 1 {
+		t.Fatalf("gaps: got %d, want 1", len(gaps))
+	}
+	wantStart := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)
+	wantEnd := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !gaps[0].Start().Equal(wantStart) || !gaps[0].End().Equal(wantEnd) {
+		t.Errorf("gap: got %v-%v, want %v-%v", gaps[0].Start(), gaps[0].End(), wantStart, wantEnd)
+	}
+
+	b.Fill(gaps, nil, testSchema(), "ALL", false)
+	if b.Len() != 4 {
+		t.Fatalf("buckets: got %d, want 4", b.Len())
+	}
+
+	gaps = b.Gaps(end.Add(15 * time.Minute))
+	if len(gaps) != 1 {
+		t.Fatalf("gaps after one interval: got %d, want 1", len(gaps))
+	}
+	if !gaps[0].Start().Equal(wantEnd) || gaps[0].End().Sub(gaps[0].Start()) != 15*time.Minute {
+		t.Errorf("steady-state gap: got %v-%v", gaps[0].Start(), gaps[0].End())
+	}
+	if b.Len() != 3 {
+		t.Errorf("oldest bucket should be evicted, got %d buckets", b.Len())
+	}
+}
+
+func TestBaseline_FillAndHistorical(t *testing.T) {
+	b := NewBaseline(30*time.Minute, time.Hour, "")
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	gaps := b.Gaps(end)
+
+	records := []ingestor.LogRecord{
+		{Status: "error", Service: "api", Message: "timeout", Timestamp: end.Add(-50 * time.Minute)},
+		{Status: "error", Service: "api", Message: "timeout", Timestamp: end.Add(-10 * time.Minute)},
+		{Status: "info", Service: "api", Message: "ok", Timestamp: end.Add(-10 * time.Minute)},
+		{Status: "error", Service: "api", Message: "too late", Timestamp: end.Add(time.Minute)},
+	}
+	b.Fill(gaps, records, testSchema(), "MEDIUM", false)
+
+	hist, truncated := b.Historical(testSchema())
+	if truncated {
+		t.Error("baseline should not be truncated")
+	}
+	intervals := hist.Dimensions["service"].Intervals
+	if len(intervals) != 2 {
+		t.Fatalf("intervals: got %d, want 2", len(intervals))
+	}
+	if intervals[0].Count != 1 || intervals[1].Count != 1 {
+		t.Errorf("counts: got %d/%d, want 1/1", intervals[0].Count, intervals[1].Count)
+	}
+	if intervals[1].Messages["timeout"] != 1 {
+		t.Errorf("timeout messages: got %d, want 1", intervals[1].Messages["timeout"])
+	}
+	if intervals[1].Values["api"] != 1 {
+		t.Errorf("api value count: got %d, want 1", intervals[1].Values["api"])
+	}
+}
+
+func TestBaseline_SnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	b := NewBaseline(15*time.Minute, time.Hour, path)
+	records := []ingestor.LogRecord{
+		{Status: "error", Service: "api", Message: "timeout", Timestamp: end.Add(-time.Minute)},
+	}
+	b.Fill(b.Gaps(end), records, testSchema(), "ALL", false)
+	if err := b.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored := NewBaseline(15*time.Minute, time.Hour, path)
+	if restored.Len() != 4 {
+		t.Fatalf("restored buckets: got %d, want 4", restored.Len())
+	}
+	if gaps := restored.Gaps(end.In(time.FixedZone("EST", -5*3600))); len(gaps) != 0 {
+		t.Errorf("restored baseline should have no gaps, got %d", len(gaps))
+	}
+	if !restored.Matches(testSchema()) {
+		t.Error("restored baseline should match the schema it was built with")
+	}
+
+	mismatched := NewBaseline(30*time.Minute, time.Hour, path)
+	if mismatched.Len() != 0 {
+		t.Error("snapshot with a different interval should be ignored")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"values":{"api":1}`) {
+		t.Fatalf("snapshot should hold value counts: %s", data)
+	}
+	old := strings.ReplaceAll(string(data), `"values":{"api":1}`, `"values":null`)
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if NewBaseline(15*time.Minute, time.Hour, path).Len() != 0 {
+		t.Error("snapshot without value counts should be ignored")
+	}
+}
@@ -1,10 +1,9 @@
 package aggregator
 
 import (
-	"strings"
 	"time"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 	"github.com/ricardonunez-io/lumberjack/internal/schema"
 )
 
@@ -16,89 +15,71 @@ type HistoricalDimensionData struct {
 	Intervals []IntervalData `json:"intervals"`
 }
 
+// IntervalData counts one dimension's logs in one interval: in total, per
+// value of the dimension, and per message.
 type IntervalData struct {
 	Messages map[string]int `json:"messages"`
+	Values   map[string]int `json:"values"`
 	Count    int            `json:"count"`
 }
 
-func AggregateHistorical(responses []datadogV2.Log, s schema.Schema, interval time.Duration, logSeverity string) HistoricalAggregates {
+func newIntervalData() IntervalData {
+	return IntervalData{Messages: make(map[string]int), Values: make(map[string]int)}
+}
+
+// historicalIntervals returns an empty interval for every field in s and
+// every interval in tr, and the index of the interval a time falls in. Both
+// the fetched and the counted baseline are binned over the whole window, so
+// a quiet stretch counts as intervals with nothing in them.
+func historicalIntervals(s schema.Schema, tr ingestor.TimeRange, interval time.Duration) (HistoricalAggregates, func(time.Time) int) {
 	result := HistoricalAggregates{
 		Dimensions: make(map[string]*HistoricalDimensionData),
 	}
 
-	if len(responses) == 0 {
-		for _, f := range s.Fields {
-			result.Dimensions[f.Name] = &HistoricalDimensionData{}
-		}
-		return result
-	}
-
-	var earliest, latest time.Time
-	initialized := false
-	for _, ddLog := range responses {
-		if ddLog.Attributes == nil || ddLog.Attributes.Timestamp == nil {
-			continue
-		}
-		ts := *ddLog.Attributes.Timestamp
-		if !initialized {
-			earliest = ts
-			latest = ts
-			initialized = true
-			continue
-		}
-		if ts.Before(earliest) {
-			earliest = ts
-		}
-		if ts.After(latest) {
-			latest = ts
-		}
+	start, end := tr.Start(), tr.End()
+	numIntervals := int((end.Sub(start) + interval - 1) / interval)
+	if numIntervals < 1 {
+		numIntervals = 1
 	}
-
-	if !initialized {
-		for _, f := range s.Fields {
-			result.Dimensions[f.Name] = &HistoricalDimensionData{}
-		}
-		return result
+	indexOf := func(ts time.Time) int {
+		return min(max(int(ts.Sub(start)/interval), 0), numIntervals-1)
 	}
 
-	numIntervals := int(latest.Sub(earliest)/interval) + 1
 	for _, f := range s.Fields {
 		hd := &HistoricalDimensionData{
 			Intervals: make([]IntervalData, numIntervals),
 		}
 		for i := range hd.Intervals {
-			hd.Intervals[i] = IntervalData{Messages: make(map[string]int)}
+			hd.Intervals[i] = newIntervalData()
 		}
 		result.Dimensions[f.Name] = hd
 	}
+	return result, indexOf
+}
 
-	for _, ddLog := range responses {
-		if ddLog.Attributes == nil || ddLog.Attributes.Timestamp == nil {
-			continue
-		}
+func AggregateHistorical(records []ingestor.LogRecord, s schema.Schema, tr ingestor.TimeRange, interval time.Duration, logSeverity string) HistoricalAggregates {
+	result, indexOf := historicalIntervals(s, tr, interval)
 
-		if ddLog.Attributes.Status != nil && ShouldSkipLog(*ddLog.Attributes.Status, logSeverity) {
+	for _, rec := range records {
+		if rec.Timestamp.IsZero() {
 			continue
 		}
 
-		ts := *ddLog.Attributes.Timestamp
-		idx := int(ts.Sub(earliest) / interval)
-		if idx < 0 || idx >= numIntervals {
+		if rec.Status != "" && ShouldSkipLog(rec.Status, logSeverity) {
 			continue
 		}
 
-		var msg string
-		if ddLog.Attributes.Message != nil {
-			msg = *ddLog.Attributes.Message
-		}
+		idx := indexOf(rec.Timestamp)
+		msg := rec.Message
 
-		values := extractHistoricalFieldValues(ddLog, s)
-		for fieldName := range values {
+		values := extractFieldValues(rec, s)
+		for fieldName, value := range values {
 			dim, ok := result.Dimensions[fieldName]
 			if !ok {
 				continue
 			}
 			dim.Intervals[idx].Count++
+			dim.Intervals[idx].Values[value]++
 			if msg != "" {
 				dim.Intervals[idx].Messages[msg]++
 			}
@@ -108,37 +89,40 @@ func AggregateHistorical(responses []datadogV2.Log, s schema.Schema, interval ti
 	return result
 }
 
-func extractHistoricalFieldValues(l datadogV2.Log, s schema.Schema) map[string]string {
-	values := make(map[string]string)
-	if l.Attributes == nil {
-		return values
-	}
+func HistoricalFromCounts(counts map[string][]ingestor.CountBucket, sample []ingestor.LogRecord, s schema.Schema, tr ingestor.TimeRange, interval time.Duration, logSeverity string) HistoricalAggregates {
+	result, indexOf := historicalIntervals(s, tr, interval)
 
-	for _, f := range s.Fields {
-		switch f.Name {
-		case "status":
-			if l.Attributes.Status != nil {
-				values[f.Name] = strings.ToLower(*l.Attributes.Status)
-			}
-		case "host":
-			if l.Attributes.Host != nil {
-				values[f.Name] = *l.Attributes.Host
+	for name, hd := range result.Dimensions {
+		for _, bucket := range counts[name] {
+			idx := indexOf(bucket.Start)
+			for value, count := range bucket.Values {
+				hd.Intervals[idx].Count += count
+				hd.Intervals[idx].Values[value] += count
 			}
-		case "service":
-			if l.Attributes.Service != nil {
-				values[f.Name] = *l.Attributes.Service
-			}
-		default:
-			val := getNestedValue(l.Attributes.Attributes, f.Name)
-			if val != "" {
-				values[f.Name] = val
+		}
+	}
+
+	for _, rec := range sample {
+		if rec.Timestamp.IsZero() || rec.Message == "" {
+			continue
+		}
+		if rec.Status != "" && ShouldSkipLog(rec.Status, logSeverity) {
+			continue
+		}
+
+		idx := indexOf(rec.Timestamp)
+		for fieldName := range extractFieldValues(rec, s) {
+			if dim, ok := result.Dimensions[fieldName]; ok {
+				dim.Intervals[idx].Messages[rec.Message]++
 			}
 		}
 	}
 
-	return values
+	return result
 }
 
+// HistoricalToAggregates sums each dimension's value counts over every
+// interval.
 func HistoricalToAggregates(hist HistoricalAggregates) Aggregates {
 	agg := Aggregates{
 		Dimensions: make(map[string]*DimensionData),
@@ -149,8 +133,8 @@ func HistoricalToAggregates(hist HistoricalAggregates) Aggregates {
 			Counts: make(map[string]int),
 		}
 		for _, interval := range hd.Intervals {
-			for msg, count := range interval.Messages {
-				dim.Counts[msg] += count
+			for value, count := range interval.Values {
+				dim.Counts[value] += count
 			}
 		}
 		agg.Dimensions[name] = dim
@@ -186,6 +170,42 @@ func ExtractHistoricalInsights(hist HistoricalAggregates, dimension string) Hist
 	}
 }
 
+// ExtractValueInsights returns, for each value the dimension took, its count
+// in every interval, counting zero where it did not occur.
+func ExtractValueInsights(hist HistoricalAggregates, dimension string) map[string]HistoricalInsights {
+	dim, ok := hist.Dimensions[dimension]
+	if !ok || len(dim.Intervals) == 0 {
+		return map[string]HistoricalInsights{}
+	}
+
+	series := make(map[string][]int)
+	for i, interval := range dim.Intervals {
+		for value, count := range interval.Values {
+			if series[value] == nil {
+				series[value] = make([]int, len(dim.Intervals))
+			}
+			series[value][i] = count
+		}
+	}
+
+	insights := make(map[string]HistoricalInsights, len(series))
+	for value, counts := range series {
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		avg := float64(total) / float64(len(counts))
+		insights[value] = HistoricalInsights{
+			TotalCount:        total,
+			AverageCount:      avg,
+			MedianCount:       CalculateMedian(counts),
+			StandardDeviation: CalculateStdDev(counts, avg),
+			IntervalCounts:    counts,
+		}
+	}
+	return insights
+}
+
 type HistoricalInsights struct {
 	TotalCount        int     `json:"totalCount"`
 	AverageCount      float64 `json:"averageCount"`
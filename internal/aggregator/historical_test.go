@@ -4,40 +4,45 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 	"github.com/ricardonunez-io/lumberjack/internal/schema"
 )
 
-func makeHistoricalLogs() []datadogV2.Log {
-	base := time.Now().Add(-2 * time.Hour)
-	return []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("web-01"), Service: strPtr("api"),
-			Message: strPtr("timeout"), Timestamp: timePtr(base),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("web-01"), Service: strPtr("api"),
-			Message: strPtr("timeout"), Timestamp: timePtr(base.Add(5 * time.Minute)),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("warning"), Host: strPtr("web-02"), Service: strPtr("worker"),
-			Message: strPtr("high memory"), Timestamp: timePtr(base.Add(30 * time.Minute)),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("web-01"), Service: strPtr("api"),
-			Message: strPtr("timeout"), Timestamp: timePtr(base.Add(time.Hour)),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("info"), Host: strPtr("web-01"), Service: strPtr("api"),
-			Message: strPtr("request ok"), Timestamp: timePtr(base.Add(90 * time.Minute)),
-		}},
+var historicalBase = time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+
+// historicalWindow is the two hours makeHistoricalLogs covers.
+var historicalWindow = ingestor.NewAbsoluteRange(historicalBase, historicalBase.Add(2*time.Hour))
+
+func makeHistoricalLogs() []ingestor.LogRecord {
+	base := historicalBase
+	return []ingestor.LogRecord{
+		{
+			Status: "error", Host: "web-01", Service: "api",
+			Message: "timeout", Timestamp: base,
+		},
+		{
+			Status: "error", Host: "web-01", Service: "api",
+			Message: "timeout", Timestamp: base.Add(5 * time.Minute),
+		},
+		{
+			Status: "warning", Host: "web-02", Service: "worker",
+			Message: "high memory", Timestamp: base.Add(30 * time.Minute),
+		},
+		{
+			Status: "error", Host: "web-01", Service: "api",
+			Message: "timeout", Timestamp: base.Add(time.Hour),
+		},
+		{
+			Status: "info", Host: "web-01", Service: "api",
+			Message: "request ok", Timestamp: base.Add(90 * time.Minute),
+		},
 	}
 }
 
 func TestAggregateHistorical_Basic(t *testing.T) {
 	logs := makeHistoricalLogs()
 	s := testSchema()
-	hist := AggregateHistorical(logs, s, 30*time.Minute, "ALL")
+	hist := AggregateHistorical(logs, s, historicalWindow, 30*time.Minute, "ALL")
 
 	statusDim := hist.Dimensions["status"]
 	if statusDim == nil {
@@ -59,7 +64,7 @@ func TestAggregateHistorical_Basic(t *testing.T) {
 func TestAggregateHistorical_SeverityFilter(t *testing.T) {
 	logs := makeHistoricalLogs()
 	s := testSchema()
-	hist := AggregateHistorical(logs, s, 30*time.Minute, "SEVERE")
+	hist := AggregateHistorical(logs, s, historicalWindow, 30*time.Minute, "SEVERE")
 
 	statusDim := hist.Dimensions["status"]
 	totalCount := 0
@@ -73,28 +78,63 @@ func TestAggregateHistorical_SeverityFilter(t *testing.T) {
 
 func TestAggregateHistorical_EmptyLogs(t *testing.T) {
 	s := testSchema()
-	hist := AggregateHistorical(nil, s, time.Hour, "ALL")
-	for _, dim := range hist.Dimensions {
-		if len(dim.Intervals) != 0 {
-			t.Error("empty logs should produce no intervals")
+	window := ingestor.NewAbsoluteRange(historicalBase, historicalBase.Add(24*time.Hour))
+	hist := AggregateHistorical(nil, s, window, time.Hour, "ALL")
+	for name, dim := range hist.Dimensions {
+		if len(dim.Intervals) != 24 {
+			t.Errorf("%s: got %d intervals, want 24 empty ones", name, len(dim.Intervals))
+		}
+		for _, interval := range dim.Intervals {
+			if interval.Count != 0 {
+				t.Errorf("%s: empty logs counted %d", name, interval.Count)
+			}
 		}
 	}
 }
 
+func TestAggregateHistorical_CoversWindow(t *testing.T) {
+	// A quiet day with logs only in its last hour.
+	window := ingestor.NewAbsoluteRange(historicalBase, historicalBase.Add(24*time.Hour))
+	var logs []ingestor.LogRecord
+	for i := range 12 {
+		logs = append(logs, ingestor.LogRecord{
+			Status: "error", Host: "h", Service: "api", Message: "timeout",
+			Timestamp: window.To.Add(-time.Hour + time.Duration(i)*5*time.Minute),
+		})
+	}
+
+	hist := AggregateHistorical(logs, testSchema(), window, time.Hour, "ALL")
+	api := ExtractValueInsights(hist, "service")["api"]
+	if len(api.IntervalCounts) != 24 || api.IntervalCounts[23] != 12 {
+		t.Fatalf("intervals: got %v, want 24 with the logs in the last", api.IntervalCounts)
+	}
+	if api.AverageCount != 0.5 {
+		t.Errorf("average: got %g, want 0.5 over the whole day", api.AverageCount)
+	}
+
+	counts := map[string][]ingestor.CountBucket{
+		"service": {{Start: window.To.Add(-time.Hour), Values: map[string]int{"api": 12}}},
+	}
+	counted := ExtractValueInsights(HistoricalFromCounts(counts, nil, testSchema(), window, time.Hour, "ALL"), "service")["api"]
+	if counted.AverageCount != api.AverageCount || counted.StandardDeviation != api.StandardDeviation {
+		t.Errorf("counted baseline %+v differs from fetched %+v", counted, api)
+	}
+}
+
 func TestAggregateHistorical_IntervalBucketing(t *testing.T) {
 	base := time.Now().Add(-time.Hour)
-	logs := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("h"), Service: strPtr("s"),
-			Message: strPtr("a"), Timestamp: timePtr(base),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("h"), Service: strPtr("s"),
-			Message: strPtr("b"), Timestamp: timePtr(base.Add(45 * time.Minute)),
-		}},
+	logs := []ingestor.LogRecord{
+		{
+			Status: "error", Host: "h", Service: "s",
+			Message: "a", Timestamp: base,
+		},
+		{
+			Status: "error", Host: "h", Service: "s",
+			Message: "b", Timestamp: base.Add(45 * time.Minute),
+		},
 	}
 	s := testSchema()
-	hist := AggregateHistorical(logs, s, 30*time.Minute, "ALL")
+	hist := AggregateHistorical(logs, s, ingestor.NewAbsoluteRange(base, base.Add(time.Hour)), 30*time.Minute, "ALL")
 
 	statusDim := hist.Dimensions["status"]
 	if len(statusDim.Intervals) < 2 {
@@ -107,22 +147,22 @@ func TestAggregateHistorical_IntervalBucketing(t *testing.T) {
 
 func TestAggregateHistorical_MessageTracking(t *testing.T) {
 	base := time.Now()
-	logs := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("h"), Service: strPtr("s"),
-			Message: strPtr("timeout"), Timestamp: timePtr(base),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("h"), Service: strPtr("s"),
-			Message: strPtr("timeout"), Timestamp: timePtr(base),
-		}},
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"), Host: strPtr("h"), Service: strPtr("s"),
-			Message: strPtr("other error"), Timestamp: timePtr(base),
-		}},
+	logs := []ingestor.LogRecord{
+		{
+			Status: "error", Host: "h", Service: "s",
+			Message: "timeout", Timestamp: base,
+		},
+		{
+			Status: "error", Host: "h", Service: "s",
+			Message: "timeout", Timestamp: base,
+		},
+		{
+			Status: "error", Host: "h", Service: "s",
+			Message: "other error", Timestamp: base,
+		},
 	}
 	s := testSchema()
-	hist := AggregateHistorical(logs, s, time.Hour, "ALL")
+	hist := AggregateHistorical(logs, s, ingestor.NewAbsoluteRange(base, base.Add(time.Hour)), time.Hour, "ALL")
 
 	statusDim := hist.Dimensions["status"]
 	if len(statusDim.Intervals) == 0 {
@@ -138,8 +178,8 @@ func TestHistoricalToAggregates(t *testing.T) {
 		Dimensions: map[string]*HistoricalDimensionData{
 			"status": {
 				Intervals: []IntervalData{
-					{Messages: map[string]int{"error": 5, "timeout": 3}, Count: 8},
-					{Messages: map[string]int{"error": 2}, Count: 2},
+					{Messages: map[string]int{"timeout": 6, "ok": 2}, Values: map[string]int{"error": 5, "info": 3}, Count: 8},
+					{Messages: map[string]int{"timeout": 2}, Values: map[string]int{"error": 2}, Count: 2},
 				},
 			},
 		},
@@ -153,8 +193,35 @@ func TestHistoricalToAggregates(t *testing.T) {
 	if statusDim.Counts["error"] != 7 {
 		t.Errorf("error count: got %d, want 7", statusDim.Counts["error"])
 	}
-	if statusDim.Counts["timeout"] != 3 {
-		t.Errorf("timeout count: got %d, want 3", statusDim.Counts["timeout"])
+	if statusDim.Counts["info"] != 3 {
+		t.Errorf("info count: got %d, want 3", statusDim.Counts["info"])
+	}
+	if _, ok := statusDim.Counts["timeout"]; ok {
+		t.Error("messages should not be counted as dimension values")
+	}
+}
+
+func TestExtractValueInsights(t *testing.T) {
+	logs := makeHistoricalLogs()
+	hist := AggregateHistorical(logs, testSchema(), historicalWindow, 30*time.Minute, "ALL")
+
+	values := ExtractValueInsights(hist, "service")
+	api, worker := values["api"], values["worker"]
+	if api.TotalCount != 4 || worker.TotalCount != 1 {
+		t.Fatalf("totals: got api %d, worker %d, want 4 and 1", api.TotalCount, worker.TotalCount)
+	}
+	if len(api.IntervalCounts) != len(hist.Dimensions["service"].Intervals) {
+		t.Errorf("api intervals: got %d, want one per interval", len(api.IntervalCounts))
+	}
+	if api.IntervalCounts[0] != 2 || worker.IntervalCounts[0] != 0 {
+		t.Errorf("first interval: got api %d, worker %d, want 2 and 0", api.IntervalCounts[0], worker.IntervalCounts[0])
+	}
+	if api.StandardDeviation == 0 {
+		t.Error("api counts vary between intervals, stddev should be positive")
+	}
+
+	if got := ExtractValueInsights(hist, "missing"); len(got) != 0 {
+		t.Errorf("missing dimension: got %v", got)
 	}
 }
 
@@ -240,15 +307,15 @@ func TestCompareHistoricalInsights_ZeroStdDev(t *testing.T) {
 
 func TestAggregateHistorical_DynamicSchema(t *testing.T) {
 	base := time.Now()
-	logs := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{
-			Status:    strPtr("error"),
-			Message:   strPtr("test"),
-			Timestamp: timePtr(base),
+	logs := []ingestor.LogRecord{
+		{
+			Status:    "error",
+			Message:   "test",
+			Timestamp: base,
 			Attributes: map[string]interface{}{
 				"env": "prod",
 			},
-		}},
+		},
 	}
 	s := schema.Schema{
 		Fields: []schema.Field{
@@ -256,7 +323,7 @@ func TestAggregateHistorical_DynamicSchema(t *testing.T) {
 			{Name: "env", Type: schema.FieldTypeString},
 		},
 	}
-	hist := AggregateHistorical(logs, s, time.Hour, "ALL")
+	hist := AggregateHistorical(logs, s, ingestor.NewAbsoluteRange(base, base.Add(time.Hour)), time.Hour, "ALL")
 
 	envDim := hist.Dimensions["env"]
 	if envDim == nil {
@@ -270,3 +337,40 @@ func TestAggregateHistorical_DynamicSchema(t *testing.T) {
 		t.Errorf("env total: got %d, want 1", totalCount)
 	}
 }
+
+func TestHistoricalFromCounts(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := ingestor.NewAbsoluteRange(start, start.Add(time.Hour))
+	counts := map[string][]ingestor.CountBucket{
+		"service": {
+			{Start: start, Values: map[string]int{"api": 4, "worker": 1}},
+			{Start: start.Add(45 * time.Minute), Values: map[string]int{"api": 2}},
+		},
+	}
+	sample := []ingestor.LogRecord{
+		{Status: "error", Service: "api", Message: "timeout", Timestamp: start.Add(time.Minute)},
+		{Status: "info", Service: "api", Message: "ok", Timestamp: start.Add(time.Minute)},
+	}
+
+	hist := HistoricalFromCounts(counts, sample, testSchema(), tr, 30*time.Minute, "MEDIUM")
+
+	svcDim := hist.Dimensions["service"]
+	if len(svcDim.Intervals) != 2 {
+		t.Fatalf("intervals: got %d, want 2", len(svcDim.Intervals))
+	}
+	if svcDim.Intervals[0].Count != 5 || svcDim.Intervals[1].Count != 2 {
+		t.Errorf("counts: got %d/%d, want 5/2", svcDim.Intervals[0].Count, svcDim.Intervals[1].Count)
+	}
+	if svcDim.Intervals[0].Values["api"] != 4 || svcDim.Intervals[0].Values["worker"] != 1 {
+		t.Errorf("value counts: got %v, want api 4, worker 1", svcDim.Intervals[0].Values)
+	}
+	if svcDim.Intervals[0].Messages["timeout"] != 1 {
+		t.Errorf("timeout messages: got %d, want 1", svcDim.Intervals[0].Messages["timeout"])
+	}
+	if _, ok := svcDim.Intervals[0].Messages["ok"]; ok {
+		t.Error("info message should be filtered at MEDIUM severity")
+	}
+	if hist.Dimensions["host"] == nil {
+		t.Error("host dimension should exist even without counts")
+	}
+}
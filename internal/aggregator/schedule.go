@@ -0,0 +1,41 @@
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+type IntervalSchedule struct {
+	Interval time.Duration
+}
+
+func (s IntervalSchedule) Next(t time.Time) time.Time {
+	return t.UTC().Truncate(s.Interval).Add(s.Interval)
+}
+
+// ParseSchedule parses a standard five-field cron expression or a descriptor
+// such as "@hourly", evaluated in the named IANA timezone (UTC when empty).
+func ParseSchedule(expr, timezone string) (Schedule, error) {
+	if !strings.HasPrefix(expr, "CRON_TZ=") && !strings.HasPrefix(expr, "TZ=") {
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		expr = "CRON_TZ=" + timezone + " " + expr
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,40 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalSchedule_Next(t *testing.T) {
+	s := IntervalSchedule{Interval: 15 * time.Minute}
+	now := time.Date(2024, 1, 1, 12, 7, 0, 0, time.UTC)
+	if got := s.Next(now); !got.Equal(time.Date(2024, 1, 1, 12, 15, 0, 0, time.UTC)) {
+		t.Errorf("got %v, want 12:15", got)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 12, 15, 0, 0, time.UTC)); !got.Equal(time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("on a boundary: got %v, want 12:30", got)
+	}
+}
+
+func TestParseSchedule_BusinessHours(t *testing.T) {
+	s, err := ParseSchedule("*/10 9-17 * * MON-FRI", "America/New_York")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+
+	ny, _ := time.LoadLocation("America/New_York")
+	friday := time.Date(2024, 1, 5, 17, 55, 0, 0, ny)
+	want := time.Date(2024, 1, 8, 9, 0, 0, 0, ny)
+	if got := s.Next(friday); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	if _, err := ParseSchedule("not a cron", ""); err == nil {
+		t.Error("invalid expression should fail")
+	}
+	if _, err := ParseSchedule("@hourly", "Mars/Olympus_Mons"); err == nil {
+		t.Error("invalid timezone should fail")
+	}
+}
@@ -30,13 +30,23 @@ var ValidLogSeverities severityOptions = severityOptions{
 	"SEVERE", // will aggregate and summarize only ERROR logs
 }
 
+func SkippedStatuses(logSeverity string) []string {
+	switch {
+	case MEDIUM.Match(logSeverity):
+		return []string{"info", "debug"}
+	case SEVERE.Match(logSeverity):
+		return []string{"info", "warning", "debug"}
+	default:
+		return nil
+	}
+}
+
 func ShouldSkipLog(logStatus string, logSeverity string) bool {
 	status := strings.ToLower(logStatus)
-	if MEDIUM.Match(logSeverity) && (status == "info" || status == "debug") {
-		return true
-	}
-	if SEVERE.Match(logSeverity) && (status == "info" || status == "warning" || status == "debug") {
-		return true
+	for _, skipped := range SkippedStatuses(logSeverity) {
+		if status == skipped {
+			return true
+		}
 	}
 	return false
 }
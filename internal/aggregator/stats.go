@@ -1,16 +1,70 @@
 package aggregator
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"sort"
 )
 
+// Comparison maps comparison metrics to values. Percent changes from zero
+// are +Inf, which JSON cannot represent, so non-finite values are encoded as
+// the strings "+Inf", "-Inf" and "NaN".
+type Comparison map[string]float64
+
+func (c Comparison) MarshalJSON() ([]byte, error) {
+	encoded := make(map[string]any, len(c))
+	for k, v := range c {
+		switch {
+		case math.IsInf(v, 1):
+			encoded[k] = "+Inf"
+		case math.IsInf(v, -1):
+			encoded[k] = "-Inf"
+		case math.IsNaN(v):
+			encoded[k] = "NaN"
+		default:
+			encoded[k] = v
+		}
+	}
+	return json.Marshal(encoded)
+}
+
+func (c *Comparison) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = make(Comparison, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case float64:
+			(*c)[k] = v
+		case string:
+			switch v {
+			case "+Inf":
+				(*c)[k] = math.Inf(1)
+			case "-Inf":
+				(*c)[k] = math.Inf(-1)
+			case "NaN":
+				(*c)[k] = math.NaN()
+			default:
+				return fmt.Errorf("comparison %q: invalid value %q", k, v)
+			}
+		default:
+			return fmt.Errorf("comparison %q: invalid value %v", k, v)
+		}
+	}
+	return nil
+}
+
 type Insights struct {
 	TotalCount        int        `json:"totalCount"`
 	UniqueKeys        int        `json:"uniqueKeys"`
 	TopKeys           []KeyCount `json:"topKeys"`
 	AverageLogsPerKey float64    `json:"averageLogsPerKey"`
+	// Values, set on historical insights, holds each value's count per
+	// interval; see ExtractValueInsights.
+	Values map[string]HistoricalInsights `json:"values,omitempty"`
 }
 
 type KeyCount struct {
@@ -45,8 +99,8 @@ func ExtractInsights(agg Aggregates, dimension string) Insights {
 	}
 }
 
-func CompareInsights(current Insights, historical Insights) map[string]float64 {
-	comparison := make(map[string]float64)
+func CompareInsights(current Insights, historical Insights) Comparison {
+	comparison := make(Comparison)
 
 	comparison["TotalCountDiff"] = float64(current.TotalCount - historical.TotalCount)
 	comparison["UniqueKeysDiff"] = float64(current.UniqueKeys - historical.UniqueKeys)
@@ -57,6 +111,10 @@ func CompareInsights(current Insights, historical Insights) map[string]float64 {
 	comparison["AverageLogsPerKeyPercentChange"] = percentageChange(historical.AverageLogsPerKey, current.AverageLogsPerKey)
 
 	for _, keyCount := range current.TopKeys {
+		if historical.Values != nil {
+			compareValue(comparison, keyCount, historical.Values[keyCount.Key])
+			continue
+		}
 		historicalCount := getHistoricalKeyCount(keyCount.Key, historical.TopKeys)
 		comparison[fmt.Sprintf("%s_CountDiff", keyCount.Key)] = float64(keyCount.Count - historicalCount)
 		comparison[fmt.Sprintf("%s_PercentChange", keyCount.Key)] = percentageChange(float64(historicalCount), float64(keyCount.Count))
@@ -65,6 +123,18 @@ func CompareInsights(current Insights, historical Insights) map[string]float64 {
 	return comparison
 }
 
+// compareValue compares a value's current count with its average count per
+// historical interval, and with the spread of those counts when they vary.
+// A value absent from the historical window has an average of zero.
+func compareValue(comparison Comparison, current KeyCount, historical HistoricalInsights) {
+	count := float64(current.Count)
+	comparison[fmt.Sprintf("%s_CountDiff", current.Key)] = count - historical.AverageCount
+	comparison[fmt.Sprintf("%s_PercentChange", current.Key)] = percentageChange(historical.AverageCount, count)
+	if historical.StandardDeviation > 0 {
+		comparison[fmt.Sprintf("%s_ZScore", current.Key)] = (count - historical.AverageCount) / historical.StandardDeviation
+	}
+}
+
 func getTopKeys(counts map[string]int, n int) []KeyCount {
 	keyCounts := make([]KeyCount, 0, len(counts))
 	for key, count := range counts {
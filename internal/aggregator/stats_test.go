@@ -1,6 +1,7 @@
 package aggregator
 
 import (
+	"encoding/json"
 	"math"
 	"testing"
 
@@ -143,6 +144,34 @@ func TestCompareInsights_TopKeysDiff(t *testing.T) {
 	}
 }
 
+func TestCompareInsights_AgainstValueHistory(t *testing.T) {
+	current := Insights{
+		TopKeys: []KeyCount{{Key: "api", Count: 30}, {Key: "batch", Count: 4}, {Key: "worker", Count: 5}},
+	}
+	historical := Insights{
+		TopKeys: []KeyCount{{Key: "api", Count: 40}},
+		Values: map[string]HistoricalInsights{
+			"api":    {AverageCount: 10, StandardDeviation: 5},
+			"worker": {AverageCount: 5},
+		},
+	}
+	comp := CompareInsights(current, historical)
+
+	if comp["api_CountDiff"] != 20 || comp["api_PercentChange"] != 200 || comp["api_ZScore"] != 4 {
+		t.Errorf("api: got diff %f, change %f, z %f, want 20, 200, 4",
+			comp["api_CountDiff"], comp["api_PercentChange"], comp["api_ZScore"])
+	}
+	if comp["worker_PercentChange"] != 0 {
+		t.Errorf("worker PercentChange: got %f, want 0", comp["worker_PercentChange"])
+	}
+	if _, ok := comp["worker_ZScore"]; ok {
+		t.Error("worker never varied, it should have no z-score")
+	}
+	if !math.IsInf(comp["batch_PercentChange"], 1) {
+		t.Errorf("batch PercentChange without history: got %f, want +Inf", comp["batch_PercentChange"])
+	}
+}
+
 func TestCalculateMedian_Odd(t *testing.T) {
 	m := CalculateMedian([]int{1, 3, 5})
 	if m != 3 {
@@ -289,3 +318,20 @@ func TestSeverityIncludes(t *testing.T) {
 		t.Error("should not include INVALID")
 	}
 }
+
+func TestComparison_JSONRoundTrip(t *testing.T) {
+	c := Comparison{"TotalCountDiff": 3, "api_PercentChange": math.Inf(1), "web_PercentChange": -50}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded Comparison
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded["TotalCountDiff"] != 3 || decoded["web_PercentChange"] != -50 || !math.IsInf(decoded["api_PercentChange"], 1) {
+		t.Errorf("round trip: got %v from %s", decoded, data)
+	}
+}
@@ -2,49 +2,144 @@ package aggregator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
 	"time"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/ricardonunez-io/lumberjack/internal/fuzzy"
 	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
 	"github.com/ricardonunez-io/lumberjack/internal/schema"
 	"github.com/rs/zerolog/log"
 )
 
 type AggregationResult struct {
-	Comparisons    map[string]map[string]float64 `json:"comparisons"`
-	CurrentLogs    Aggregates                    `json:"currentLogs"`
-	HistoricalLogs HistoricalAggregates          `json:"historicalLogs"`
-	Schema         schema.Schema                 `json:"schema"`
+	Monitor          string                 `json:"monitor"`
+	Comparisons      map[string]Comparison  `json:"comparisons"`
+	CurrentLogs      Aggregates             `json:"currentLogs"`
+	HistoricalLogs   HistoricalAggregates   `json:"historicalLogs"`
+	Schema           schema.Schema          `json:"schema"`
+	Truncated        bool                   `json:"truncated"`
+	LogCount         int                    `json:"logCount"`
+	Window           ingestor.AbsoluteRange `json:"window"`
+	HistoricalWindow ingestor.AbsoluteRange `json:"historicalWindow"`
 }
 
 type AggregationConfig struct {
-	Client                    *datadog.APIClient
-	TimeInterval              time.Duration
-	Query                     string
-	LogSeverity               string
-	TimeIntervalKey           string
-	HistoricalTimeIntervalKey string
-	SchemaCache               *schema.Cache
+	Name                   string
+	Dimensions             []string
+	Source                 ingestor.LogSource
+	TimeInterval           time.Duration
+	Query                  string
+	LogSeverity            string
+	HistoricalTimeInterval time.Duration
+	IngestionDelay         time.Duration
+	Schedule               Schedule
+	SchemaCache            *schema.Cache
+	ServerSideHistorical   bool
+	HistoricalSampleSize   int
+	SimilarityThreshold    float64
+	Baseline               *Baseline
+
+	// Overlap decides what happens to a cycle that comes due while the
+	// previous one is still running; the default is OverlapSkip.
+	Overlap string
+	// CycleTimeout bounds each cycle; the default is TimeInterval.
+	CycleTimeout time.Duration
+
+	// LastWindowEnd is where a previous loop for the monitor left off. The
+	// loop skips its startup cycle when that window has already run, so a
+	// restart mid-interval does not analyze and notify it again.
+	LastWindowEnd time.Time
+
+	// Trigger runs a cycle for the latest complete window as soon as it
+	// receives, outside the schedule.
+	Trigger <-chan struct{}
+	// Observer, when set, is told about cycles that are scheduled, fail or
+	// are missed.
+	Observer Observer
+}
+
+// Overlap policies for a cycle that comes due while another is running.
+const (
+	// OverlapSkip drops the new cycle.
+	OverlapSkip = "skip"
+	// OverlapQueue runs the new cycle once the running one finishes.
+	OverlapQueue = "queue"
+	// OverlapCancel cancels the running cycle and then runs the new one.
+	OverlapCancel = "cancel"
+)
+
+var OverlapPolicies = []string{OverlapSkip, OverlapQueue, OverlapCancel}
+
+// Reasons a cycle was missed.
+const (
+	MissedSkipped   = "skipped"
+	MissedCancelled = "cancelled"
+	MissedLate      = "late"
+	MissedDropped   = "dropped"
+)
+
+// resultBuffer is how many results may wait for a slow consumer.
+const resultBuffer = 4
+
+// Observer follows a periodic loop. Its methods are called from the loop's
+// goroutine.
+type Observer interface {
+	CycleScheduled(at time.Time)
+	CycleFailed(end time.Time, err error)
+	CycleMissed(end time.Time, reason string)
 }
 
 func RunPeriodicAggregation(ctx context.Context, cfg AggregationConfig) <-chan AggregationResult {
-	log.Info().Msg("Starting periodic aggregation")
-	resultChan := make(chan AggregationResult)
+	log.Info().Str("monitor", cfg.Name).Msg("Starting periodic aggregation")
+	resultChan := make(chan AggregationResult, resultBuffer)
 
 	go func() {
 		defer close(resultChan)
 
-		runAggregation(cfg, resultChan)
-		ticker := time.NewTicker(cfg.TimeInterval)
-		defer ticker.Stop()
+		schedule := cfg.Schedule
+		if schedule == nil {
+			schedule = IntervalSchedule{Interval: cfg.TimeInterval}
+		}
+
+		l := &loop{ctx: ctx, cfg: cfg, results: resultChan, finished: make(chan cycleOutcome)}
+		defer l.stop()
+
+		if first := LatestWindowEnd(cfg, time.Now()); first.After(cfg.LastWindowEnd) {
+			l.due(first)
+		} else {
+			log.Info().Str("monitor", cfg.Name).Time("windowEnd", first).Msg("Latest window already ran, waiting for the next one")
+		}
+
+		next := schedule.Next(time.Now().Add(-cfg.IngestionDelay))
+		timer := time.NewTimer(time.Until(next.Add(cfg.IngestionDelay)))
+		defer timer.Stop()
+		observeScheduled(cfg, next)
 
 		for {
 			select {
 			case <-ctx.Done():
-				log.Info().Msg("Stopping periodic aggregation")
+				log.Info().Str("monitor", cfg.Name).Msg("Stopping periodic aggregation")
 				return
-			case <-ticker.C:
-				runAggregation(cfg, resultChan)
+			case <-cfg.Trigger:
+				log.Info().Str("monitor", cfg.Name).Msg("Running triggered aggregation cycle")
+				l.due(LatestWindowEnd(cfg, time.Now()))
+			case <-timer.C:
+				l.due(next)
+				following := schedule.Next(time.Now().Add(-cfg.IngestionDelay))
+				// Windows between the two were due while the loop was not
+				// running, e.g. across a suspend.
+				for end := schedule.Next(next); end.Before(following); end = schedule.Next(end) {
+					l.missed(end, MissedLate)
+				}
+				next = following
+				timer.Reset(time.Until(next.Add(cfg.IngestionDelay)))
+				observeScheduled(cfg, next)
+			case outcome := <-l.finished:
+				l.complete(outcome)
 			}
 		}
 	}()
@@ -52,49 +147,420 @@ func RunPeriodicAggregation(ctx context.Context, cfg AggregationConfig) <-chan A
 	return resultChan
 }
 
-func runAggregation(cfg AggregationConfig, resultChan chan<- AggregationResult) {
-	log.Info().Msg("Running aggregation cycle")
+// CycleTimeout is the deadline each of cfg's cycles runs under.
+func CycleTimeout(cfg AggregationConfig) time.Duration {
+	if cfg.CycleTimeout > 0 {
+		return cfg.CycleTimeout
+	}
+	return cfg.TimeInterval
+}
 
-	currentLogs, err := ingestor.GetIngestorFromTimeInterval(cfg.TimeIntervalKey, cfg.Query, cfg.Client)
-	if err != nil {
-		log.Err(err).Msg("Failed to ingest logs for current interval")
+type cycleOutcome struct {
+	end    time.Time
+	result AggregationResult
+	err    error
+}
+
+// loop runs a periodic aggregation's cycles one at a time, each in its own
+// goroutine so the schedule keeps ticking while a cycle is slow. Its fields
+// are owned by the goroutine in RunPeriodicAggregation.
+type loop struct {
+	ctx      context.Context
+	cfg      AggregationConfig
+	results  chan AggregationResult
+	finished chan cycleOutcome
+
+	running   bool
+	end       time.Time
+	cancel    context.CancelFunc
+	cancelled bool
+	queue     []time.Time
+}
+
+// due starts the cycle for the window ending at end, or applies the overlap
+// policy when a cycle is still running.
+func (l *loop) due(end time.Time) {
+	if !l.running {
+		l.start(end)
+		return
+	}
+	if end.Equal(l.end) || slices.ContainsFunc(l.queue, end.Equal) {
+		// Already running or queued, e.g. triggered during its own cycle.
 		return
 	}
 
-	historicalLogs, err := ingestor.GetIngestorFromTimeInterval(cfg.HistoricalTimeIntervalKey, cfg.Query, cfg.Client)
-	if err != nil {
-		log.Err(err).Msg("Failed to ingest logs for historical interval")
+	switch l.cfg.Overlap {
+	case OverlapQueue:
+		l.queue = append(l.queue, end)
+	case OverlapCancel:
+		log.Warn().
+			Str("monitor", l.cfg.Name).
+			Time("windowEnd", l.end).
+			Msg("Cancelling the running aggregation cycle for a newer window")
+		l.cancelled = true
+		l.cancel()
+		for _, queued := range l.queue {
+			l.missed(queued, MissedCancelled)
+		}
+		l.queue = []time.Time{end}
+	default:
+		l.missed(end, MissedSkipped)
+	}
+}
+
+func (l *loop) start(end time.Time) {
+	timeout := CycleTimeout(l.cfg)
+	ctx, cancel := context.WithTimeout(l.ctx, timeout)
+	l.running, l.end, l.cancel, l.cancelled = true, end, cancel, false
+
+	go func() {
+		defer cancel()
+		result, err := RunCycle(ctx, l.cfg, end)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("cycle exceeded its %s deadline: %w", timeout, err)
+		}
+		l.finished <- cycleOutcome{end: end, result: result, err: err}
+	}()
+}
+
+// complete hands a finished cycle's result to the consumer and starts the
+// next queued cycle.
+func (l *loop) complete(outcome cycleOutcome) {
+	l.running = false
+	switch {
+	case l.ctx.Err() != nil:
+		return
+	case outcome.err != nil && l.cancelled:
+		l.missed(outcome.end, MissedCancelled)
+	case outcome.err != nil:
+		log.Err(outcome.err).Str("monitor", l.cfg.Name).Msg("Aggregation cycle failed")
+		if l.cfg.Observer != nil {
+			l.cfg.Observer.CycleFailed(outcome.end, outcome.err)
+		}
+	default:
+		l.deliver(outcome.result)
+	}
+
+	if len(l.queue) > 0 {
+		end := l.queue[0]
+		l.queue = l.queue[1:]
+		l.start(end)
+	}
+}
+
+// deliver buffers result for the consumer without waiting for it, so slow
+// analysis or notification never holds up ingestion. When the consumer is
+// resultBuffer results behind, the oldest waiting result is dropped.
+func (l *loop) deliver(result AggregationResult) {
+	select {
+	case l.results <- result:
+		return
+	default:
+	}
+
+	select {
+	case dropped := <-l.results:
+		l.missed(dropped.Window.To, MissedDropped)
+	default:
+	}
+	l.results <- result
+}
+
+func (l *loop) missed(end time.Time, reason string) {
+	log.Warn().
+		Str("monitor", l.cfg.Name).
+		Time("windowEnd", end).
+		Str("reason", reason).
+		Msg("Aggregation cycle missed")
+	metrics.MissedCycles.WithLabelValues(l.cfg.Name, reason).Inc()
+	if l.cfg.Observer != nil {
+		l.cfg.Observer.CycleMissed(end, reason)
+	}
+}
+
+// stop cancels any running cycle and waits for it to return, keeping its
+// result if it finished anyway.
+func (l *loop) stop() {
+	if !l.running {
 		return
 	}
+	l.cancel()
+	outcome := <-l.finished
+	l.running = false
+	if outcome.err == nil {
+		l.deliver(outcome.result)
+	}
+}
+
+func observeScheduled(cfg AggregationConfig, next time.Time) {
+	if cfg.Observer != nil {
+		cfg.Observer.CycleScheduled(next.Add(cfg.IngestionDelay))
+	}
+}
+
+// LatestWindowEnd returns the end of the most recent complete window at now,
+// which is the window a loop started at now runs first.
+func LatestWindowEnd(cfg AggregationConfig, now time.Time) time.Time {
+	align := cfg.TimeInterval
+	if cfg.Schedule != nil {
+		align = time.Minute
+	}
+	return ingestor.AlignedWindow(now, cfg.TimeInterval, align, cfg.IngestionDelay).To
+}
 
-	allLogs := append(currentLogs, historicalLogs...)
-	s := cfg.SchemaCache.Get(allLogs)
+// WindowEnds returns the end of every scheduled window that ends within
+// (from, to], in order.
+func WindowEnds(cfg AggregationConfig, from, to time.Time) []time.Time {
+	var schedule Schedule = IntervalSchedule{Interval: cfg.TimeInterval}
+	if cfg.Schedule != nil {
+		schedule = cfg.Schedule
+	}
+
+	var ends []time.Time
+	for end := schedule.Next(from); !end.After(to); end = schedule.Next(end) {
+		ends = append(ends, end)
+	}
+	return ends
+}
+
+// RunCycle aggregates the window ending at end against its historical
+// window and returns the result without delivering it.
+func RunCycle(ctx context.Context, cfg AggregationConfig, end time.Time) (AggregationResult, error) {
+	c := &Cycle{Monitor: cfg.Name, WindowEnd: end}
+	ctx = context.WithValue(metrics.WithMonitor(ctx, cfg.Name), cycleContextKey{}, c)
+	result, err := runCycle(ctx, cfg, end)
+	metrics.ObserveCycle(ctx, err)
+	if err != nil {
+		c.fail()
+	}
+	return result, err
+}
+
+// Cycle identifies the cycle a source call is made for. A monitor's cycles
+// can overlap, so wrappers around its source tell them apart with
+// CycleFrom rather than by the order of calls.
+type Cycle struct {
+	Monitor   string
+	WindowEnd time.Time
+
+	mu        sync.Mutex
+	onFailure []func()
+}
+
+type cycleContextKey struct{}
+
+// CycleFrom returns the cycle RunCycle is running ctx for.
+func CycleFrom(ctx context.Context) (*Cycle, bool) {
+	c, ok := ctx.Value(cycleContextKey{}).(*Cycle)
+	return c, ok
+}
+
+// OnFailure registers f to run if the cycle fails or is cancelled.
+func (c *Cycle) OnFailure(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onFailure = append(c.onFailure, f)
+}
+
+func (c *Cycle) fail() {
+	c.mu.Lock()
+	fs := c.onFailure
+	c.onFailure = nil
+	c.mu.Unlock()
+	for _, f := range fs {
+		f()
+	}
+}
+
+func runCycle(ctx context.Context, cfg AggregationConfig, end time.Time) (AggregationResult, error) {
+	window := ingestor.NewAbsoluteRange(end.Add(-cfg.TimeInterval), end)
+	historicalWindow := ingestor.NewAbsoluteRange(window.From.Add(-cfg.HistoricalTimeInterval), window.From)
 
 	log.Info().
+		Str("monitor", cfg.Name).
+		Time("windowStart", window.From).
+		Time("windowEnd", window.To).
+		Msg("Running aggregation cycle")
+
+	truncated := false
+
+	currentLogs, err := ingest(ctx, cfg, window)
+	currentTruncated := errors.Is(err, ingestor.ErrTruncated)
+	if currentTruncated {
+		log.Warn().Str("monitor", cfg.Name).Msg("Current interval logs truncated by fetch limits")
+		truncated = true
+	} else if err != nil {
+		return AggregationResult{}, fmt.Errorf("failed to ingest logs for current interval: %w", err)
+	}
+	metrics.WindowLogs.WithLabelValues(cfg.Name).Observe(float64(len(currentLogs)))
+
+	var s schema.Schema
+	var historicalAggregates HistoricalAggregates
+	var historicalTruncated bool
+	countSource, isCountSource := cfg.Source.(ingestor.CountSource)
+
+	switch {
+	case cfg.Baseline != nil:
+		s, historicalAggregates, historicalTruncated, err = baselineHistorical(ctx, cfg, historicalWindow, currentLogs)
+	case cfg.ServerSideHistorical && isCountSource:
+		s, historicalAggregates, err = countedHistorical(ctx, cfg, countSource, historicalWindow, currentLogs)
+	default:
+		s, historicalAggregates, historicalTruncated, err = fetchedHistorical(ctx, cfg, historicalWindow, currentLogs)
+	}
+	if err != nil {
+		return AggregationResult{}, fmt.Errorf("failed to build historical aggregates: %w", err)
+	}
+	if historicalTruncated {
+		log.Warn().Str("monitor", cfg.Name).Msg("Historical interval logs truncated by fetch limits")
+		truncated = true
+	}
+
+	log.Info().
+		Str("monitor", cfg.Name).
 		Int("schemaFields", len(s.Fields)).
 		Int("currentLogs", len(currentLogs)).
-		Int("historicalLogs", len(historicalLogs)).
 		Msg("Schema resolved")
 
-	currentAggregates := Aggregate(currentLogs, s, cfg.LogSeverity)
-	historicalAggregates := AggregateHistorical(historicalLogs, s, cfg.TimeInterval, cfg.LogSeverity)
+	threshold := cfg.SimilarityThreshold
+	if threshold == 0 {
+		threshold = fuzzy.DefaultSimilarityThreshold
+	}
+	stopAggregate := metrics.Stage(ctx, metrics.StageAggregate)
+	currentAggregates := countDimensions(currentLogs, s, cfg.LogSeverity)
+	stopAggregate()
+
+	stopFuzzy := metrics.Stage(ctx, metrics.StageFuzzy)
+	groupMessages(currentAggregates, threshold)
+	stopFuzzy()
+
+	defer metrics.Stage(ctx, metrics.StageAggregate)()
+	if cfg.Baseline != nil && window.From.Equal(window.From.Truncate(cfg.TimeInterval)) {
+		cfg.Baseline.Fill([]ingestor.TimeRange{window}, currentLogs, s, cfg.LogSeverity, currentTruncated)
+		if err := cfg.Baseline.Save(); err != nil {
+			log.Warn().Err(err).Str("monitor", cfg.Name).Msg("Failed to save historical baseline snapshot")
+		}
+	}
 
-	comparisons := make(map[string]map[string]float64)
+	comparisons := make(map[string]Comparison)
 	historicalAsAggregates := HistoricalToAggregates(historicalAggregates)
 
 	for _, f := range s.Fields {
 		currentInsights := ExtractInsights(currentAggregates, f.Name)
 		historicalInsights := ExtractInsights(historicalAsAggregates, f.Name)
+		historicalInsights.Values = ExtractValueInsights(historicalAggregates, f.Name)
 		comparison := CompareInsights(currentInsights, historicalInsights)
 		comparisons[f.Name] = comparison
 	}
 
-	resultChan <- AggregationResult{
-		Comparisons:    comparisons,
-		CurrentLogs:    currentAggregates,
-		HistoricalLogs: historicalAggregates,
-		Schema:         s,
+	log.Info().Str("monitor", cfg.Name).Msg("Aggregation cycle completed")
+
+	return AggregationResult{
+		Monitor:          cfg.Name,
+		Comparisons:      comparisons,
+		CurrentLogs:      currentAggregates,
+		HistoricalLogs:   historicalAggregates,
+		Schema:           s,
+		Truncated:        truncated,
+		LogCount:         len(currentLogs),
+		Window:           window,
+		HistoricalWindow: historicalWindow,
+	}, nil
+}
+
+// ingest fetches tr from the monitor's source, timed as the ingest stage.
+func ingest(ctx context.Context, cfg AggregationConfig, tr ingestor.TimeRange) ([]ingestor.LogRecord, error) {
+	defer metrics.Stage(ctx, metrics.StageIngest)()
+	return ingestor.IngestWithinTimeRange(ctx, tr, cfg.Source, cfg.Query)
+}
+
+func resolveSchema(ctx context.Context, cfg AggregationConfig, logs []ingestor.LogRecord) schema.Schema {
+	defer metrics.Stage(ctx, metrics.StageSchema)()
+	s := cfg.SchemaCache.Get(logs)
+	if len(cfg.Dimensions) > 0 {
+		s = s.Select(cfg.Dimensions)
+	}
+	return s
+}
+
+func fetchedHistorical(ctx context.Context, cfg AggregationConfig, historicalWindow ingestor.AbsoluteRange, currentLogs []ingestor.LogRecord) (schema.Schema, HistoricalAggregates, bool, error) {
+	truncated := false
+	historicalLogs, err := ingest(ctx, cfg, historicalWindow)
+	if errors.Is(err, ingestor.ErrTruncated) {
+		truncated = true
+	} else if err != nil {
+		return schema.Schema{}, HistoricalAggregates{}, false, err
+	}
+
+	s := resolveSchema(ctx, cfg, append(currentLogs, historicalLogs...))
+	log.Info().Int("historicalLogs", len(historicalLogs)).Msg("Fetched historical interval")
+
+	defer metrics.Stage(ctx, metrics.StageAggregate)()
+	return s, AggregateHistorical(historicalLogs, s, historicalWindow, cfg.TimeInterval, cfg.LogSeverity), truncated, nil
+}
+
+func countedHistorical(ctx context.Context, cfg AggregationConfig, source ingestor.CountSource, historicalRange ingestor.AbsoluteRange, currentLogs []ingestor.LogRecord) (schema.Schema, HistoricalAggregates, error) {
+	stopIngest := metrics.Stage(ctx, metrics.StageIngest)
+	sample, err := source.Sample(ctx, historicalRange, cfg.Query, cfg.HistoricalSampleSize)
+	stopIngest()
+	if err != nil {
+		return schema.Schema{}, HistoricalAggregates{}, err
+	}
+
+	s := resolveSchema(ctx, cfg, append(currentLogs, sample...))
+	stopIngest = metrics.Stage(ctx, metrics.StageIngest)
+	counts, err := source.CountByDimension(ctx, historicalRange, cfg.Query, s.FieldNames(), cfg.TimeInterval, SkippedStatuses(cfg.LogSeverity))
+	stopIngest()
+	if err != nil {
+		return schema.Schema{}, HistoricalAggregates{}, err
+	}
+	log.Info().Int("sampledLogs", len(sample)).Msg("Counted historical interval server-side")
+
+	defer metrics.Stage(ctx, metrics.StageAggregate)()
+	return s, HistoricalFromCounts(counts, sample, s, historicalRange, cfg.TimeInterval, cfg.LogSeverity), nil
+}
+
+func baselineHistorical(ctx context.Context, cfg AggregationConfig, historicalWindow ingestor.AbsoluteRange, currentLogs []ingestor.LogRecord) (schema.Schema, HistoricalAggregates, bool, error) {
+	gaps := cfg.Baseline.Gaps(historicalWindow.To)
+	backfill, truncated, err := fetchRanges(ctx, cfg, gaps)
+	if err != nil {
+		return schema.Schema{}, HistoricalAggregates{}, false, err
+	}
+
+	s := resolveSchema(ctx, cfg, append(currentLogs, backfill...))
+	if !cfg.Baseline.Matches(s) {
+		log.Info().Msg("Schema changed, rebuilding historical baseline")
+		cfg.Baseline.Reset()
+		gaps = cfg.Baseline.Gaps(historicalWindow.To)
+		backfill, truncated, err = fetchRanges(ctx, cfg, gaps)
+		if err != nil {
+			return schema.Schema{}, HistoricalAggregates{}, false, err
+		}
 	}
 
-	log.Info().Msg("Aggregation cycle completed")
+	defer metrics.Stage(ctx, metrics.StageAggregate)()
+	cfg.Baseline.Fill(gaps, backfill, s, cfg.LogSeverity, truncated)
+
+	log.Info().
+		Int("gaps", len(gaps)).
+		Int("backfilledLogs", len(backfill)).
+		Int("buckets", cfg.Baseline.Len()).
+		Msg("Historical baseline updated")
+
+	historical, truncated := cfg.Baseline.Historical(s)
+	return s, historical, truncated, nil
+}
+
+func fetchRanges(ctx context.Context, cfg AggregationConfig, ranges []ingestor.TimeRange) ([]ingestor.LogRecord, bool, error) {
+	var records []ingestor.LogRecord
+	truncated := false
+	for _, tr := range ranges {
+		logs, err := ingest(ctx, cfg, tr)
+		if errors.Is(err, ingestor.ErrTruncated) {
+			truncated = true
+		} else if err != nil {
+			return nil, false, err
+		}
+		records = append(records, logs...)
+	}
+	return records, truncated, nil
 }
@@ -0,0 +1,265 @@
+package aggregator
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+)
+
+type stubSource struct {
+	records []ingestor.LogRecord
+	ranges  []ingestor.TimeRange
+}
+
+func (s *stubSource) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	s.ranges = append(s.ranges, tr)
+	var out []ingestor.LogRecord
+	for _, r := range s.records {
+		if !r.Timestamp.Before(tr.Start()) && r.Timestamp.Before(tr.End()) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func TestRunAggregation_TagsMonitorAndSelectsDimensions(t *testing.T) {
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	src := &stubSource{records: []ingestor.LogRecord{
+		{Status: "error", Host: "web-01", Service: "api", Message: "timeout", Timestamp: end.Add(-5 * time.Minute)},
+		{Status: "error", Host: "web-01", Service: "api", Message: "timeout", Timestamp: end.Add(-2 * time.Hour)},
+	}}
+	cfg := AggregationConfig{
+		Name:                   "api",
+		Dimensions:             []string{"service"},
+		Source:                 src,
+		TimeInterval:           15 * time.Minute,
+		HistoricalTimeInterval: 24 * time.Hour,
+		LogSeverity:            "ALL",
+		SchemaCache:            schema.NewCache(10),
+	}
+
+	result, err := RunCycle(context.Background(), cfg, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Monitor != "api" {
+		t.Errorf("Monitor: got %q, want api", result.Monitor)
+	}
+	if names := result.Schema.FieldNames(); len(names) != 1 || names[0] != "service" {
+		t.Errorf("schema fields: got %v, want [service]", names)
+	}
+	if !result.Window.From.Equal(end.Add(-15*time.Minute)) || !result.Window.To.Equal(end) {
+		t.Errorf("window: got %v-%v", result.Window.From, result.Window.To)
+	}
+	if !result.HistoricalWindow.To.Equal(result.Window.From) {
+		t.Errorf("historical window should end where the current window starts, got %v", result.HistoricalWindow.To)
+	}
+	if result.CurrentLogs.Dimensions["service"].Counts["api"] != 1 {
+		t.Errorf("current api count: got %d, want 1", result.CurrentLogs.Dimensions["service"].Counts["api"])
+	}
+}
+
+func TestWindowEnds(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	ends := WindowEnds(AggregationConfig{TimeInterval: 15 * time.Minute}, from, to)
+	if len(ends) != 4 || !ends[0].Equal(from.Add(15*time.Minute)) || !ends[3].Equal(to) {
+		t.Errorf("interval ends: got %v", ends)
+	}
+
+	schedule, err := ParseSchedule("0,20 * * * *", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ends = WindowEnds(AggregationConfig{TimeInterval: 15 * time.Minute, Schedule: schedule}, from, to)
+	want := []time.Time{from.Add(20 * time.Minute), from.Add(time.Hour)}
+	if len(ends) != len(want) || !ends[0].Equal(want[0]) || !ends[1].Equal(want[1]) {
+		t.Errorf("cron ends: got %v, want %v", ends, want)
+	}
+}
+
+func TestLatestWindowEnd(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 22, 30, 0, time.UTC)
+	cfg := AggregationConfig{TimeInterval: 15 * time.Minute, IngestionDelay: time.Minute}
+
+	if got, want := LatestWindowEnd(cfg, now), time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("interval: got %v, want %v", got, want)
+	}
+
+	cfg.Schedule = IntervalSchedule{Interval: time.Minute}
+	if got, want := LatestWindowEnd(cfg, now), time.Date(2024, 1, 1, 10, 21, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("scheduled: got %v, want %v", got, want)
+	}
+}
+
+type recordingObserver struct {
+	scheduled chan time.Time
+	failed    []error
+	missed    []string
+}
+
+func (o *recordingObserver) CycleScheduled(at time.Time)          { o.scheduled <- at }
+func (o *recordingObserver) CycleFailed(end time.Time, err error) { o.failed = append(o.failed, err) }
+func (o *recordingObserver) CycleMissed(end time.Time, reason string) {
+	o.missed = append(o.missed, reason)
+}
+
+func TestRunPeriodicAggregation_Trigger(t *testing.T) {
+	trigger := make(chan struct{}, 1)
+	observer := &recordingObserver{scheduled: make(chan time.Time, 1)}
+	cfg := AggregationConfig{
+		Name:                   "api",
+		Source:                 &stubSource{},
+		TimeInterval:           time.Hour,
+		HistoricalTimeInterval: 24 * time.Hour,
+		LogSeverity:            "ALL",
+		SchemaCache:            schema.NewCache(10),
+		Trigger:                trigger,
+		Observer:               observer,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := RunPeriodicAggregation(ctx, cfg)
+
+	first := <-results
+	select {
+	case at := <-observer.scheduled:
+		if !at.After(first.Window.To) {
+			t.Errorf("next run %v should be after the first window %v", at, first.Window.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("next run was not reported")
+	}
+
+	trigger <- struct{}{}
+	select {
+	case second := <-results:
+		if !second.Window.To.Equal(first.Window.To) {
+			t.Errorf("triggered window: got %v, want the latest window %v", second.Window.To, first.Window.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("triggered cycle did not run")
+	}
+}
+
+// blockingSource holds every fetch until release is closed, and fails it
+// if its context is done by then.
+type blockingSource struct {
+	release chan struct{}
+}
+
+func (s *blockingSource) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+	}
+	return nil, ctx.Err()
+}
+
+func newTestLoop(overlap string) (*loop, *blockingSource, *recordingObserver) {
+	src := &blockingSource{release: make(chan struct{})}
+	observer := &recordingObserver{}
+	cfg := AggregationConfig{
+		Name:                   "api",
+		Source:                 src,
+		TimeInterval:           time.Hour,
+		HistoricalTimeInterval: 24 * time.Hour,
+		LogSeverity:            "ALL",
+		SchemaCache:            schema.NewCache(10),
+		Overlap:                overlap,
+		Observer:               observer,
+	}
+	l := &loop{
+		ctx:      context.Background(),
+		cfg:      cfg,
+		results:  make(chan AggregationResult, resultBuffer),
+		finished: make(chan cycleOutcome),
+	}
+	return l, src, observer
+}
+
+func windowEnds(results chan AggregationResult) []time.Time {
+	var ends []time.Time
+	for len(results) > 0 {
+		ends = append(ends, (<-results).Window.To)
+	}
+	return ends
+}
+
+func TestLoop_OverlapPolicies(t *testing.T) {
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	tests := []struct {
+		overlap string
+		want    []time.Time
+		missed  []string
+	}{
+		{OverlapSkip, []time.Time{first}, []string{MissedSkipped}},
+		{OverlapQueue, []time.Time{first, second}, nil},
+		{OverlapCancel, []time.Time{second}, []string{MissedCancelled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.overlap, func(t *testing.T) {
+			l, src, observer := newTestLoop(tt.overlap)
+			l.due(first)
+			l.due(second)
+			l.due(first)
+			close(src.release)
+
+			for l.running {
+				l.complete(<-l.finished)
+			}
+
+			if got := windowEnds(l.results); !slices.EqualFunc(got, tt.want, time.Time.Equal) {
+				t.Errorf("delivered windows: got %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(observer.missed, tt.missed) {
+				t.Errorf("missed: got %v, want %v", observer.missed, tt.missed)
+			}
+			if len(observer.failed) > 0 {
+				t.Errorf("failed: got %v", observer.failed)
+			}
+		})
+	}
+}
+
+func TestLoop_CycleDeadline(t *testing.T) {
+	l, _, observer := newTestLoop(OverlapSkip)
+	l.cfg.CycleTimeout = 10 * time.Millisecond
+
+	l.due(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	l.complete(<-l.finished)
+
+	if len(observer.failed) != 1 || !strings.Contains(observer.failed[0].Error(), "deadline") {
+		t.Errorf("failed: got %v, want a deadline error", observer.failed)
+	}
+	if len(l.results) != 0 {
+		t.Errorf("a cycle past its deadline should not deliver a result")
+	}
+}
+
+func TestLoop_DropsOldestResultWhenConsumerIsBehind(t *testing.T) {
+	l, _, observer := newTestLoop(OverlapSkip)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := range resultBuffer + 1 {
+		l.deliver(AggregationResult{Window: ingestor.NewAbsoluteRange(start, start.Add(time.Duration(i+1)*time.Hour))})
+	}
+
+	got := windowEnds(l.results)
+	if len(got) != resultBuffer || !got[0].Equal(start.Add(2*time.Hour)) {
+		t.Errorf("buffered windows: got %v, want the newest %d", got, resultBuffer)
+	}
+	if !slices.Equal(observer.missed, []string{MissedDropped}) {
+		t.Errorf("missed: got %v, want [dropped]", observer.missed)
+	}
+}
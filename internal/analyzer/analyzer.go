@@ -9,6 +9,7 @@ import (
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/invopop/jsonschema"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
 )
 
 type AnalysisResult struct {
@@ -18,9 +19,18 @@ type AnalysisResult struct {
 	Reasoning      string   `json:"reasoning" jsonschema:"description=Concise explanation of the analysis"`
 	KeyPoints      []string `json:"keyPoints" jsonschema:"description=List of key observations"`
 	Timestamp      string   `json:"timestamp" jsonschema:"description=ISO 8601 timestamp of the analysis"`
+	Monitor        string   `json:"monitor,omitempty" jsonschema:"-"`
 }
 
 func Analyze(ctx context.Context, inputData string, cfg Config) (*AnalysisResult, error) {
+	result, err := analyze(ctx, inputData, cfg)
+	if err != nil {
+		metrics.AnalyzerErrors.WithLabelValues(metrics.Monitor(ctx)).Inc()
+	}
+	return result, err
+}
+
+func analyze(ctx context.Context, inputData string, cfg Config) (*AnalysisResult, error) {
 	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("ANTHROPIC_API_KEY is not set")
 	}
@@ -31,6 +41,8 @@ func Analyze(ctx context.Context, inputData string, cfg Config) (*AnalysisResult
 
 	outputSchema := generateSchema(&AnalysisResult{})
 
+	monitor := metrics.Monitor(ctx)
+	start := time.Now()
 	message, err := client.Messages.New(ctx, anthropic.MessageNewParams{
 		Model:     anthropic.Model(cfg.Model),
 		MaxTokens: 2048,
@@ -46,9 +58,12 @@ func Analyze(ctx context.Context, inputData string, cfg Config) (*AnalysisResult
 			},
 		},
 	})
+	metrics.AnalyzerDuration.WithLabelValues(monitor).Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, fmt.Errorf("anthropic API error: %w", err)
 	}
+	metrics.AnalyzerTokens.WithLabelValues(monitor, "input").Add(float64(message.Usage.InputTokens))
+	metrics.AnalyzerTokens.WithLabelValues(monitor, "output").Add(float64(message.Usage.OutputTokens))
 
 	if len(message.Content) == 0 {
 		return nil, fmt.Errorf("empty response from anthropic")
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/fuzzy"
+	"github.com/ricardonunez-io/lumberjack/internal/leader"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
+)
+
+const DestinationSlack = "slack"
+
+type Config struct {
+	Anthropic      AnthropicConfig  `json:"anthropic"`
+	Slack          SlackConfig      `json:"slack"`
+	Sources        SourcesConfig    `json:"sources"`
+	Historical     HistoricalConfig `json:"historical"`
+	Schema         SchemaConfig     `json:"schema"`
+	Fuzzy          FuzzyConfig      `json:"fuzzy"`
+	State          StateConfig      `json:"state"`
+	Admin          AdminConfig      `json:"admin"`
+	Shutdown       ShutdownConfig   `json:"shutdown"`
+	Leader         LeaderConfig     `json:"leader"`
+	IngestionDelay string           `json:"ingestionDelay"`
+	Defaults       Monitor          `json:"defaults"`
+	Monitors       []Monitor        `json:"monitors"`
+}
+
+type AnthropicConfig struct {
+	APIKey string `json:"apiKey"`
+	Model  string `json:"model"`
+}
+
+type SlackConfig struct {
+	BotToken  string `json:"botToken"`
+	ChannelID string `json:"channelId"`
+}
+
+type SourcesConfig struct {
+	DataDog       DataDogConfig       `json:"datadog"`
+	File          FileConfig          `json:"file"`
+	Loki          LokiConfig          `json:"loki"`
+	Elasticsearch ElasticsearchConfig `json:"elasticsearch"`
+	OTLP          OTLPConfig          `json:"otlp"`
+	Syslog        SyslogConfig        `json:"syslog"`
+}
+
+type DataDogConfig struct {
+	APIKey         string      `json:"apiKey"`
+	ApplicationKey string      `json:"applicationKey"`
+	Site           string      `json:"site"`
+	PageLimit      int         `json:"pageLimit"`
+	MaxLogs        int         `json:"maxLogs"`
+	MaxPages       int         `json:"maxPages"`
+	Retry          RetryConfig `json:"retry"`
+}
+
+type RetryConfig struct {
+	MaxAttempts    int    `json:"maxAttempts"`
+	InitialBackoff string `json:"initialBackoff"`
+	MaxBackoff     string `json:"maxBackoff"`
+}
+
+type FileConfig struct {
+	Path            string `json:"path"`
+	Format          string `json:"format"`
+	TimestampField  string `json:"timestampField"`
+	TimestampLayout string `json:"timestampLayout"`
+	LinePattern     string `json:"linePattern"`
+}
+
+type LokiConfig struct {
+	URL       string `json:"url"`
+	TenantID  string `json:"tenantId"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	PageLimit int    `json:"pageLimit"`
+}
+
+type ElasticsearchConfig struct {
+	URL            string `json:"url"`
+	Index          string `json:"index"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	APIKey         string `json:"apiKey"`
+	PageSize       int    `json:"pageSize"`
+	TimestampField string `json:"timestampField"`
+	MessageField   string `json:"messageField"`
+	StatusField    string `json:"statusField"`
+	HostField      string `json:"hostField"`
+	ServiceField   string `json:"serviceField"`
+}
+
+type OTLPConfig struct {
+	ListenAddr string `json:"listenAddr"`
+	BufferSize int    `json:"bufferSize"`
+}
+
+type SyslogConfig struct {
+	UDPAddr    string `json:"udpAddr"`
+	TCPAddr    string `json:"tcpAddr"`
+	BufferSize int    `json:"bufferSize"`
+}
+
+// DefaultSyslogAddr is where syslog is received, over both UDP and TCP, when
+// neither address is set.
+const DefaultSyslogAddr = ":5514"
+
+// Addrs returns the UDP and TCP listen addresses; an empty one is disabled.
+func (c SyslogConfig) Addrs() (udp, tcp string) {
+	if c.UDPAddr == "" && c.TCPAddr == "" {
+		return DefaultSyslogAddr, DefaultSyslogAddr
+	}
+	return c.UDPAddr, c.TCPAddr
+}
+
+type HistoricalConfig struct {
+	ServerSideCounts bool   `json:"serverSideCounts"`
+	SampleSize       int    `json:"sampleSize"`
+	Baseline         bool   `json:"baseline"`
+	BaselinePath     string `json:"baselinePath"`
+}
+
+type SchemaConfig struct {
+	RefreshEvery int `json:"refreshEvery"`
+	SampleSize   int `json:"sampleSize"`
+}
+
+type FuzzyConfig struct {
+	SimilarityThreshold float64 `json:"similarityThreshold"`
+}
+
+type StateConfig struct {
+	Backend   string `json:"backend"`
+	Path      string `json:"path"`
+	MaxAge    string `json:"maxAge"`
+	MaxCycles int    `json:"maxCycles"`
+}
+
+type AdminConfig struct {
+	ListenAddr     string `json:"listenAddr"`
+	ReadyIntervals int    `json:"readyIntervals"`
+}
+
+type ShutdownConfig struct {
+	GracePeriod string `json:"gracePeriod"`
+	OutboxPath  string `json:"outboxPath"`
+}
+
+type LeaderConfig struct {
+	Backend string `json:"backend"`
+	Path    string `json:"path"`
+	Holder  string `json:"holder"`
+	TTL     string `json:"ttl"`
+}
+
+type Destination struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+}
+
+type Monitor struct {
+	Name                   string        `json:"name"`
+	Query                  string        `json:"query"`
+	Source                 string        `json:"source"`
+	Severity               string        `json:"severity"`
+	TimeInterval           string        `json:"timeInterval"`
+	HistoricalTimeInterval string        `json:"historicalTimeInterval"`
+	Schedule               string        `json:"schedule"`
+	ScheduleTimezone       string        `json:"scheduleTimezone"`
+	Overlap                string        `json:"overlap"`
+	CycleTimeout           string        `json:"cycleTimeout"`
+	Dimensions             []string      `json:"dimensions"`
+	Destinations           []Destination `json:"destinations"`
+}
+
+func Defaults() Config {
+	return Config{
+		Anthropic: AnthropicConfig{
+			Model: analyzer.DefaultConfig("").Model,
+		},
+		Sources: SourcesConfig{
+			File: FileConfig{Format: "json"},
+			OTLP: OTLPConfig{ListenAddr: ":4318"},
+		},
+		Historical: HistoricalConfig{
+			SampleSize: 1000,
+		},
+		Schema: SchemaConfig{
+			RefreshEvery: 10,
+			SampleSize:   schema.DefaultSampleSize,
+		},
+		Fuzzy: FuzzyConfig{
+			SimilarityThreshold: fuzzy.DefaultSimilarityThreshold,
+		},
+		State: StateConfig{
+			Backend: state.BackendFile,
+			MaxAge:  "720h",
+		},
+		Admin: AdminConfig{
+			ReadyIntervals: 3,
+		},
+		Shutdown: ShutdownConfig{
+			GracePeriod: "30s",
+		},
+		Leader: LeaderConfig{
+			Backend: leader.BackendNone,
+			TTL:     "15s",
+		},
+		IngestionDelay: "0s",
+		Defaults: Monitor{
+			Name:                   "default",
+			Query:                  "*",
+			Source:                 "datadog",
+			Severity:               "MEDIUM",
+			TimeInterval:           "FIFTEEN_MINUTES",
+			HistoricalTimeInterval: "ONE_DAY",
+			Overlap:                aggregator.OverlapSkip,
+		},
+	}
+}
+
+// ResolvedMonitors returns the configured monitors with unset fields taken
+// from Defaults, or a single monitor built from Defaults when none are
+// configured.
+func (c Config) ResolvedMonitors() []Monitor {
+	defaults := c.Defaults
+	if defaults.Destinations == nil && c.Slack.ChannelID != "" {
+		defaults.Destinations = []Destination{{Type: DestinationSlack, Channel: c.Slack.ChannelID}}
+	}
+
+	if len(c.Monitors) == 0 {
+		return []Monitor{defaults}
+	}
+
+	monitors := make([]Monitor, len(c.Monitors))
+	for i, m := range c.Monitors {
+		monitors[i] = m.WithDefaults(defaults)
+	}
+	return monitors
+}
+
+func (m Monitor) WithDefaults(defaults Monitor) Monitor {
+	fields := []struct{ value, fallback *string }{
+		{&m.Query, &defaults.Query},
+		{&m.Source, &defaults.Source},
+		{&m.Severity, &defaults.Severity},
+		{&m.TimeInterval, &defaults.TimeInterval},
+		{&m.HistoricalTimeInterval, &defaults.HistoricalTimeInterval},
+		{&m.Schedule, &defaults.Schedule},
+		{&m.ScheduleTimezone, &defaults.ScheduleTimezone},
+		{&m.Overlap, &defaults.Overlap},
+		{&m.CycleTimeout, &defaults.CycleTimeout},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = *f.fallback
+		}
+	}
+	if m.Dimensions == nil {
+		m.Dimensions = defaults.Dimensions
+	}
+	if m.Destinations == nil {
+		m.Destinations = defaults.Destinations
+	}
+	return m
+}
@@ -0,0 +1,351 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	return writeFile(t, "monitors.json", content)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMonitors(t *testing.T) {
+	path := writeConfig(t, `{"monitors": [
+		{"name": "api", "query": "service:api", "dimensions": ["status", "host"]},
+		{"name": "payments", "severity": "SEVERE", "destinations": [{"type": "slack", "channel": "C1"}]}
+	]}`)
+
+	monitors, err := LoadMonitors(path)
+	if err != nil {
+		t.Fatalf("LoadMonitors: %v", err)
+	}
+	if len(monitors) != 2 {
+		t.Fatalf("got %d monitors, want 2", len(monitors))
+	}
+	if monitors[0].Query != "service:api" || len(monitors[0].Dimensions) != 2 {
+		t.Errorf("api monitor: got %+v", monitors[0])
+	}
+	if monitors[1].Destinations[0].Channel != "C1" {
+		t.Errorf("payments destination: got %+v", monitors[1].Destinations)
+	}
+}
+
+func TestLoadMonitors_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":  `{"monitors": [{"name": "a", "qurey": "x"}]}`,
+		"no monitors":    `{"monitors": []}`,
+		"malformed json": `{"monitors": [`,
+	}
+	for name, content := range tests {
+		if _, err := LoadMonitors(writeConfig(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMonitor_WithDefaults(t *testing.T) {
+	defaults := Monitor{
+		Name:         "default",
+		Query:        "*",
+		Source:       "datadog",
+		Severity:     "MEDIUM",
+		TimeInterval: "FIFTEEN_MINUTES",
+		Destinations: []Destination{{Type: DestinationSlack, Channel: "C0"}},
+	}
+	m := Monitor{Name: "api", Severity: "SEVERE"}.WithDefaults(defaults)
+
+	if m.Name != "api" || m.Severity != "SEVERE" {
+		t.Errorf("own fields should be kept, got %+v", m)
+	}
+	if m.Query != "*" || m.Source != "datadog" || m.TimeInterval != "FIFTEEN_MINUTES" {
+		t.Errorf("missing fields should fall back to defaults, got %+v", m)
+	}
+	if len(m.Destinations) != 1 || m.Destinations[0].Channel != "C0" {
+		t.Errorf("destinations: got %+v", m.Destinations)
+	}
+}
+
+func validConfig() Config {
+	cfg := Defaults()
+	cfg.Anthropic.APIKey = "sk-test"
+	cfg.Slack.BotToken = "xoxb-test"
+	cfg.Slack.ChannelID = "C0"
+	cfg.Sources.DataDog.APIKey = "dd-api"
+	cfg.Sources.DataDog.ApplicationKey = "dd-app"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(validConfig()); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	tests := map[string]func(*Config){
+		"missing api key":        func(c *Config) { c.Anthropic.APIKey = "" },
+		"missing bot token":      func(c *Config) { c.Slack.BotToken = "" },
+		"missing name":           func(c *Config) { c.Monitors = []Monitor{{Query: "x"}} },
+		"duplicate name":         func(c *Config) { c.Monitors = []Monitor{{Name: "a"}, {Name: "a"}} },
+		"bad destination":        func(c *Config) { c.Monitors = []Monitor{{Name: "a", Destinations: []Destination{{Type: "pager"}}}} },
+		"missing channel":        func(c *Config) { c.Monitors = []Monitor{{Name: "a", Destinations: []Destination{{Type: "slack"}}}} },
+		"no destination":         func(c *Config) { c.Slack.ChannelID = "" },
+		"unknown source":         func(c *Config) { c.Defaults.Source = "splunk" },
+		"unknown severity":       func(c *Config) { c.Defaults.Severity = "LOUD" },
+		"bad interval":           func(c *Config) { c.Defaults.TimeInterval = "sometimes" },
+		"historical too short":   func(c *Config) { c.Defaults.HistoricalTimeInterval = "10m" },
+		"bad schedule":           func(c *Config) { c.Defaults.Schedule = "every day" },
+		"bad timezone":           func(c *Config) { c.Defaults.Schedule, c.Defaults.ScheduleTimezone = "0 9 * * *", "Mars/Base" },
+		"unknown overlap":        func(c *Config) { c.Defaults.Overlap = "wait" },
+		"zero cycle timeout":     func(c *Config) { c.Defaults.CycleTimeout = "0s" },
+		"negative delay":         func(c *Config) { c.IngestionDelay = "-1m" },
+		"bad threshold":          func(c *Config) { c.Fuzzy.SimilarityThreshold = 1.5 },
+		"zero schema refresh":    func(c *Config) { c.Schema.RefreshEvery = 0 },
+		"unknown state backend":  func(c *Config) { c.State.Backend = "redis" },
+		"bad state max age":      func(c *Config) { c.State.MaxAge = "forever" },
+		"zero ready intervals":   func(c *Config) { c.Admin.ReadyIntervals = 0 },
+		"bad grace period":       func(c *Config) { c.Shutdown.GracePeriod = "-5s" },
+		"unknown leader backend": func(c *Config) { c.Leader.Backend = "etcd" },
+		"zero leader ttl":        func(c *Config) { c.Leader.TTL = "0s" },
+		"missing datadog keys":   func(c *Config) { c.Sources.DataDog.APIKey = "" },
+		"bad datadog site":       func(c *Config) { c.Sources.DataDog.Site = "example.com" },
+		"page limit too large":   func(c *Config) { c.Sources.DataDog.PageLimit = 100000 },
+		"bad retry backoff":      func(c *Config) { c.Sources.DataDog.Retry.MaxBackoff = "soon" },
+		"file leader without path": func(c *Config) {
+			c.Leader.Backend = "file"
+		},
+		"both historical modes": func(c *Config) {
+			c.Historical.ServerSideCounts, c.Historical.Baseline = true, true
+		},
+		"server-side counts without datadog": func(c *Config) {
+			c.Historical.ServerSideCounts = true
+			c.Defaults.Source = "loki"
+			c.Sources.Loki.URL = "http://loki:3100"
+		},
+		"file without path": func(c *Config) { c.Defaults.Source = "file" },
+		"otlp on the admin port": func(c *Config) {
+			c.Defaults.Source = "otlp"
+			c.Admin.ListenAddr = "127.0.0.1:4318"
+		},
+		"syslog on the otlp port": func(c *Config) {
+			c.Monitors = []Monitor{{Name: "a", Source: "otlp"}, {Name: "b", Source: "syslog"}}
+			c.Sources.Syslog.TCPAddr = ":4318"
+		},
+		"text without pattern": func(c *Config) {
+			c.Defaults.Source, c.Sources.File.Path, c.Sources.File.Format = "file", "app.log", "text"
+		},
+		"loki without url": func(c *Config) { c.Defaults.Source = "loki" },
+		"es without index": func(c *Config) { c.Defaults.Source, c.Sources.Elasticsearch.URL = "elasticsearch", "http://es:9200" },
+	}
+	for name, mutate := range tests {
+		cfg := validConfig()
+		mutate(&cfg)
+		if err := Validate(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidate_DryRunNeedsNoBotToken(t *testing.T) {
+	cfg := validConfig()
+	cfg.Slack.BotToken = ""
+	if err := ValidateWith(cfg, Options{DryRun: true}); err != nil {
+		t.Errorf("dry run without a bot token: %v", err)
+	}
+	if err := Validate(cfg); err == nil {
+		t.Error("posting without a bot token: expected an error")
+	}
+}
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.Anthropic.APIKey = ""
+	cfg.Defaults.Severity = "LOUD"
+	cfg.Fuzzy.SimilarityThreshold = 0
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"anthropic.apiKey", "severity", "similarityThreshold"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("DD_SITE", "")
+
+	files := map[string]string{
+		"lumberjack.yaml": `
+anthropic:
+  apiKey: sk-test
+slack:
+  botToken: xoxb-test
+  channelId: C0
+sources:
+  datadog:
+    apiKey: dd-api
+    applicationKey: dd-app
+    site: datadoghq.eu
+monitors:
+  - name: api
+    query: service:api
+    timeInterval: 5m
+    historicalTimeInterval: 24h
+`,
+		"lumberjack.toml": `
+[anthropic]
+apiKey = "sk-test"
+
+[slack]
+botToken = "xoxb-test"
+channelId = "C0"
+
+[sources.datadog]
+apiKey = "dd-api"
+applicationKey = "dd-app"
+site = "datadoghq.eu"
+
+[[monitors]]
+name = "api"
+query = "service:api"
+timeInterval = "5m"
+historicalTimeInterval = "24h"
+`,
+		"lumberjack.json": `{
+	"anthropic": {"apiKey": "sk-test"},
+	"slack": {"botToken": "xoxb-test", "channelId": "C0"},
+	"sources": {"datadog": {"apiKey": "dd-api", "applicationKey": "dd-app", "site": "datadoghq.eu"}},
+	"monitors": [{"name": "api", "query": "service:api", "timeInterval": "5m", "historicalTimeInterval": "24h"}]
+}`,
+	}
+	for name, content := range files {
+		cfg, err := Load(writeFile(t, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if cfg.Sources.DataDog.Site != "datadoghq.eu" {
+			t.Errorf("%s: site = %q", name, cfg.Sources.DataDog.Site)
+		}
+		monitors := cfg.ResolvedMonitors()
+		if len(monitors) != 1 || monitors[0].Query != "service:api" || monitors[0].Severity != "MEDIUM" {
+			t.Errorf("%s: monitors = %+v", name, monitors)
+		}
+		if cfg.Schema.RefreshEvery != 10 {
+			t.Errorf("%s: defaults should survive decoding, got refreshEvery %d", name, cfg.Schema.RefreshEvery)
+		}
+	}
+}
+
+func TestLoad_RejectsUnknownKeys(t *testing.T) {
+	path := writeFile(t, "lumberjack.yaml", `
+anthropic:
+  apiKey: sk-test
+  modle: claude
+`)
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "modle") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestLoad_Interpolation(t *testing.T) {
+	t.Setenv("TEST_SLACK_TOKEN", "xoxb-from-env")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-env")
+	t.Setenv("DD_API_KEY", "dd-api")
+	t.Setenv("DD_APPLICATION_KEY", "dd-app")
+
+	path := writeFile(t, "lumberjack.yaml", `
+# comments may mention ${TEST_UNSET_IN_COMMENT}
+slack:
+  botToken: ${TEST_SLACK_TOKEN}  # or set ${TEST_UNSET_IN_COMMENT}
+  channelId: "#${TEST_SLACK_CHANNEL:-C-default}" # a quoted # is not a comment
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Slack.BotToken != "xoxb-from-env" || cfg.Slack.ChannelID != "#C-default" {
+		t.Errorf("slack = %+v", cfg.Slack)
+	}
+
+	path = writeFile(t, "lumberjack.yaml", `
+slack:
+  botToken: ${TEST_UNSET_TOKEN}
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "TEST_UNSET_TOKEN") {
+		t.Errorf("expected unset variable error, got %v", err)
+	}
+}
+
+func TestLoad_InterpolatedValuesStayValues(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "sk-env")
+	t.Setenv("DD_API_KEY", "dd-api")
+	t.Setenv("DD_APPLICATION_KEY", "dd-app")
+	token := "xo\"xb: \\secret\nchannelId: injected"
+	t.Setenv("TEST_SLACK_TOKEN", token)
+	t.Setenv("TEST_SCHEMA_REFRESH", "3")
+
+	files := map[string]string{
+		"lumberjack.yaml": `
+slack:
+  botToken: ${TEST_SLACK_TOKEN}
+  channelId: "${TEST_SLACK_CHANNEL:-C0}"
+schema:
+  refreshEvery: ${TEST_SCHEMA_REFRESH}
+`,
+		"lumberjack.toml": `
+[slack]
+botToken = "${TEST_SLACK_TOKEN}"
+channelId = "${TEST_SLACK_CHANNEL:-C0}"
+`,
+		"lumberjack.json": `{"slack": {"botToken": "${TEST_SLACK_TOKEN}", "channelId": "${TEST_SLACK_CHANNEL:-C0}"}}`,
+	}
+	for name, content := range files {
+		cfg, err := Load(writeFile(t, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if cfg.Slack.BotToken != token || cfg.Slack.ChannelID != "C0" {
+			t.Errorf("%s: slack = %+v", name, cfg.Slack)
+		}
+		if name == "lumberjack.yaml" && cfg.Schema.RefreshEvery != 3 {
+			t.Errorf("%s: unquoted number: got refreshEvery %d, want 3", name, cfg.Schema.RefreshEvery)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("LOG_SEVERITY", "SEVERE")
+	t.Setenv("DD_PAGE_LIMIT", "500")
+	t.Setenv("HISTORICAL_BASELINE", "true")
+	t.Setenv("FUZZY_SIMILARITY_THRESHOLD", "0.9")
+
+	cfg := Defaults()
+	if err := ApplyEnv(&cfg); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	if cfg.Defaults.Severity != "SEVERE" || cfg.Sources.DataDog.PageLimit != 500 ||
+		!cfg.Historical.Baseline || cfg.Fuzzy.SimilarityThreshold != 0.9 {
+		t.Errorf("env overrides not applied: %+v", cfg)
+	}
+
+	t.Setenv("DD_PAGE_LIMIT", "lots")
+	if err := ApplyEnv(&cfg); err == nil {
+		t.Error("expected an error for a non-integer DD_PAGE_LIMIT")
+	}
+}
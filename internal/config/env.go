@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ApplyEnv overrides cfg with every supported environment variable that is
+// set, so existing env-only deployments keep working and secrets can be
+// injected without editing the config file.
+func ApplyEnv(cfg *Config) error {
+	stringVars := map[string]*string{
+		"ANTHROPIC_API_KEY":  &cfg.Anthropic.APIKey,
+		"ANTHROPIC_MODEL":    &cfg.Anthropic.Model,
+		"SLACK_BOT_TOKEN":    &cfg.Slack.BotToken,
+		"SLACK_CHANNEL_ID":   &cfg.Slack.ChannelID,
+		"INGESTION_DELAY":    &cfg.IngestionDelay,
+		"DD_QUERY":           &cfg.Defaults.Query,
+		"LOG_SOURCE":         &cfg.Defaults.Source,
+		"LOG_SEVERITY":       &cfg.Defaults.Severity,
+		"TIME_INTERVAL":      &cfg.Defaults.TimeInterval,
+		"SCHEDULE":           &cfg.Defaults.Schedule,
+		"SCHEDULE_TIMEZONE":  &cfg.Defaults.ScheduleTimezone,
+		"OVERLAP":            &cfg.Defaults.Overlap,
+		"CYCLE_TIMEOUT":      &cfg.Defaults.CycleTimeout,
+		"DD_API_KEY":         &cfg.Sources.DataDog.APIKey,
+		"DD_APPLICATION_KEY": &cfg.Sources.DataDog.ApplicationKey,
+		"DD_SITE":            &cfg.Sources.DataDog.Site,
+
+		"HISTORICAL_TIME_INTERVAL": &cfg.Defaults.HistoricalTimeInterval,
+		"HISTORICAL_BASELINE_PATH": &cfg.Historical.BaselinePath,
+		"DD_RETRY_INITIAL_BACKOFF": &cfg.Sources.DataDog.Retry.InitialBackoff,
+		"DD_RETRY_MAX_BACKOFF":     &cfg.Sources.DataDog.Retry.MaxBackoff,
+
+		"FILE_PATH":             &cfg.Sources.File.Path,
+		"FILE_FORMAT":           &cfg.Sources.File.Format,
+		"FILE_TIMESTAMP_FIELD":  &cfg.Sources.File.TimestampField,
+		"FILE_TIMESTAMP_LAYOUT": &cfg.Sources.File.TimestampLayout,
+		"FILE_LINE_PATTERN":     &cfg.Sources.File.LinePattern,
+
+		"LOKI_URL":       &cfg.Sources.Loki.URL,
+		"LOKI_TENANT_ID": &cfg.Sources.Loki.TenantID,
+		"LOKI_USERNAME":  &cfg.Sources.Loki.Username,
+		"LOKI_PASSWORD":  &cfg.Sources.Loki.Password,
+
+		"ES_URL":             &cfg.Sources.Elasticsearch.URL,
+		"ES_INDEX":           &cfg.Sources.Elasticsearch.Index,
+		"ES_USERNAME":        &cfg.Sources.Elasticsearch.Username,
+		"ES_PASSWORD":        &cfg.Sources.Elasticsearch.Password,
+		"ES_API_KEY":         &cfg.Sources.Elasticsearch.APIKey,
+		"ES_TIMESTAMP_FIELD": &cfg.Sources.Elasticsearch.TimestampField,
+		"ES_MESSAGE_FIELD":   &cfg.Sources.Elasticsearch.MessageField,
+		"ES_STATUS_FIELD":    &cfg.Sources.Elasticsearch.StatusField,
+		"ES_HOST_FIELD":      &cfg.Sources.Elasticsearch.HostField,
+		"ES_SERVICE_FIELD":   &cfg.Sources.Elasticsearch.ServiceField,
+
+		"OTLP_LISTEN_ADDR": &cfg.Sources.OTLP.ListenAddr,
+		"SYSLOG_UDP_ADDR":  &cfg.Sources.Syslog.UDPAddr,
+		"SYSLOG_TCP_ADDR":  &cfg.Sources.Syslog.TCPAddr,
+
+		"STATE_BACKEND": &cfg.State.Backend,
+		"STATE_PATH":    &cfg.State.Path,
+		"STATE_MAX_AGE": &cfg.State.MaxAge,
+
+		"ADMIN_LISTEN_ADDR": &cfg.Admin.ListenAddr,
+
+		"SHUTDOWN_GRACE_PERIOD": &cfg.Shutdown.GracePeriod,
+		"SHUTDOWN_OUTBOX_PATH":  &cfg.Shutdown.OutboxPath,
+
+		"LEADER_BACKEND": &cfg.Leader.Backend,
+		"LEADER_PATH":    &cfg.Leader.Path,
+		"LEADER_HOLDER":  &cfg.Leader.Holder,
+		"LEADER_TTL":     &cfg.Leader.TTL,
+	}
+	intVars := map[string]*int{
+		"DD_PAGE_LIMIT":          &cfg.Sources.DataDog.PageLimit,
+		"DD_MAX_LOGS":            &cfg.Sources.DataDog.MaxLogs,
+		"DD_MAX_PAGES":           &cfg.Sources.DataDog.MaxPages,
+		"DD_RETRY_MAX_ATTEMPTS":  &cfg.Sources.DataDog.Retry.MaxAttempts,
+		"LOKI_PAGE_LIMIT":        &cfg.Sources.Loki.PageLimit,
+		"ES_PAGE_SIZE":           &cfg.Sources.Elasticsearch.PageSize,
+		"OTLP_BUFFER_SIZE":       &cfg.Sources.OTLP.BufferSize,
+		"SYSLOG_BUFFER_SIZE":     &cfg.Sources.Syslog.BufferSize,
+		"HISTORICAL_SAMPLE_SIZE": &cfg.Historical.SampleSize,
+		"SCHEMA_REFRESH_EVERY":   &cfg.Schema.RefreshEvery,
+		"SCHEMA_SAMPLE_SIZE":     &cfg.Schema.SampleSize,
+		"STATE_MAX_CYCLES":       &cfg.State.MaxCycles,
+		"ADMIN_READY_INTERVALS":  &cfg.Admin.ReadyIntervals,
+	}
+	boolVars := map[string]*bool{
+		"HISTORICAL_SERVER_SIDE_COUNTS": &cfg.Historical.ServerSideCounts,
+		"HISTORICAL_BASELINE":           &cfg.Historical.Baseline,
+	}
+	floatVars := map[string]*float64{
+		"FUZZY_SIMILARITY_THRESHOLD": &cfg.Fuzzy.SimilarityThreshold,
+	}
+
+	var errs []error
+	for env, field := range stringVars {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	for env, field := range intVars {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", env, v))
+				continue
+			}
+			*field = n
+		}
+	}
+	for env, field := range boolVars {
+		if v := os.Getenv(env); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", env, v))
+				continue
+			}
+			*field = b
+		}
+	}
+	for env, field := range floatVars {
+		if v := os.Getenv(env); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", env, v))
+				continue
+			}
+			*field = f
+		}
+	}
+
+	if path := os.Getenv("MONITORS_FILE"); path != "" {
+		monitors, err := LoadMonitors(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MONITORS_FILE: %w", err))
+		} else {
+			cfg.Monitors = monitors
+		}
+	}
+
+	return errors.Join(errs...)
+}
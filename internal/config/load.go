@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Options adjust what Load and Validate require for how the configuration
+// will be used.
+type Options struct {
+	// DryRun skips settings only needed to post notifications.
+	DryRun bool
+}
+
+// Load builds the configuration from the defaults, the file at path (if
+// any) and then environment variable overrides, and validates the result.
+func Load(path string) (Config, error) {
+	return LoadWith(path, Options{})
+}
+
+func LoadWith(path string, opts Options) (Config, error) {
+	cfg := Defaults()
+	if path != "" {
+		if err := decodeFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := ApplyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := ValidateWith(cfg, opts); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func LoadMonitors(path string) ([]Monitor, error) {
+	var f struct {
+		Monitors []Monitor `json:"monitors"`
+	}
+	if err := decodeFile(path, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Monitors) == 0 {
+		return nil, fmt.Errorf("%s: at least one monitor is required", path)
+	}
+	return f.Monitors, nil
+}
+
+// decodeFile parses the file, interpolates ${VAR} references in its string
+// values, converts YAML and TOML to JSON and strictly decodes the result into
+// v, rejecting unknown keys so typos fail loudly.
+func decodeFile(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	var errs []error
+	expand := func(s string) string {
+		expanded, err := interpolate(s)
+		errs = append(errs, err)
+		return expanded
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		doc = interpolateValue(doc, expand)
+	case ".yaml", ".yml":
+		var node yaml.Node
+		if err := yaml.Unmarshal(raw, &node); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if node.Kind != 0 {
+			interpolateNode(&node, expand)
+			var m map[string]any
+			if err := node.Decode(&m); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			doc = m
+		}
+	case ".toml":
+		var m map[string]any
+		if _, err := toml.Decode(string(raw), &m); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		doc = interpolateValue(m, expand)
+	default:
+		return fmt.Errorf("%s: unsupported config format %q, use .yaml, .toml or .json", path, ext)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate expands ${VAR} and ${VAR:-default} references in s.
+func interpolate(s string) (string, error) {
+	var errs []error
+	expanded := envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envReference.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(m[1]); ok && v != "" {
+			return v
+		}
+		if strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		errs = append(errs, fmt.Errorf("environment variable %s is not set", m[1]))
+		return ""
+	})
+	return expanded, errors.Join(errs...)
+}
+
+// interpolateNode expands the scalars of a parsed YAML document, so a value
+// is never read as YAML and comments are left alone. An unquoted scalar is
+// typed again from what it expands to, so `bufferSize: ${BUFFER_SIZE}` is
+// still a number.
+func interpolateNode(n *yaml.Node, expand func(string) string) {
+	if n.Kind == yaml.ScalarNode && envReference.MatchString(n.Value) {
+		n.Value = expand(n.Value)
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+	}
+	for _, c := range n.Content {
+		interpolateNode(c, expand)
+	}
+}
+
+// interpolateValue expands the strings of a parsed JSON or TOML document.
+func interpolateValue(v any, expand func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return expand(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = interpolateValue(e, expand)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = interpolateValue(e, expand)
+		}
+	case []map[string]any:
+		for _, e := range v {
+			interpolateValue(e, expand)
+		}
+	}
+	return v
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/leader"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
+)
+
+var SourceKinds = []string{
+	"datadog",
+	"file",
+	"loki",
+	ingestor.FlavorElasticsearch,
+	ingestor.FlavorOpenSearch,
+	"otlp",
+	"syslog",
+}
+
+// Validate reports every problem in cfg at once so a bad deploy fails with
+// the full list instead of one error per restart.
+func Validate(cfg Config) error {
+	return ValidateWith(cfg, Options{})
+}
+
+func ValidateWith(cfg Config, opts Options) error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Anthropic.APIKey == "" {
+		fail("anthropic.apiKey (ANTHROPIC_API_KEY) is required")
+	}
+	if cfg.Anthropic.Model == "" {
+		fail("anthropic.model must not be empty")
+	}
+	if cfg.Slack.BotToken == "" && !opts.DryRun {
+		fail("slack.botToken (SLACK_BOT_TOKEN) is required")
+	}
+
+	if d, err := time.ParseDuration(cfg.IngestionDelay); err != nil || d < 0 {
+		fail("ingestionDelay: %q is not a non-negative duration", cfg.IngestionDelay)
+	}
+	if cfg.Schema.RefreshEvery <= 0 {
+		fail("schema.refreshEvery must be positive, got %d", cfg.Schema.RefreshEvery)
+	}
+	if cfg.Schema.SampleSize <= 0 {
+		fail("schema.sampleSize must be positive, got %d", cfg.Schema.SampleSize)
+	}
+	if t := cfg.Fuzzy.SimilarityThreshold; t <= 0 || t > 1 {
+		fail("fuzzy.similarityThreshold must be in (0, 1], got %g", t)
+	}
+	if cfg.Historical.ServerSideCounts && cfg.Historical.Baseline {
+		fail("historical.serverSideCounts and historical.baseline cannot both be enabled")
+	}
+	if cfg.Historical.SampleSize <= 0 {
+		fail("historical.sampleSize must be positive, got %d", cfg.Historical.SampleSize)
+	}
+
+	if !slices.Contains(state.Backends, cfg.State.Backend) {
+		fail("state.backend: unknown backend %q, must be one of %v", cfg.State.Backend, state.Backends)
+	}
+	if cfg.State.MaxAge != "" {
+		if d, err := time.ParseDuration(cfg.State.MaxAge); err != nil || d < 0 {
+			fail("state.maxAge: %q is not a non-negative duration", cfg.State.MaxAge)
+		}
+	}
+	if cfg.State.MaxCycles < 0 {
+		fail("state.maxCycles must not be negative, got %d", cfg.State.MaxCycles)
+	}
+
+	if cfg.Admin.ReadyIntervals <= 0 {
+		fail("admin.readyIntervals must be positive, got %d", cfg.Admin.ReadyIntervals)
+	}
+	if d, err := time.ParseDuration(cfg.Shutdown.GracePeriod); err != nil || d < 0 {
+		fail("shutdown.gracePeriod: %q is not a non-negative duration", cfg.Shutdown.GracePeriod)
+	}
+	if !slices.Contains(leader.Backends, cfg.Leader.Backend) {
+		fail("leader.backend: unknown backend %q, must be one of %v", cfg.Leader.Backend, leader.Backends)
+	}
+	if cfg.Leader.Backend == leader.BackendFile && cfg.Leader.Path == "" {
+		fail("leader.path is required with the file backend, on storage every instance shares")
+	}
+	if d, err := time.ParseDuration(cfg.Leader.TTL); err != nil || d <= 0 {
+		fail("leader.ttl: %q is not a positive duration", cfg.Leader.TTL)
+	}
+
+	monitors := cfg.ResolvedMonitors()
+	used := make(map[string]bool)
+	seen := make(map[string]bool)
+	for i, m := range monitors {
+		name := m.Name
+		if name == "" {
+			fail("monitors[%d]: name is required", i)
+			name = fmt.Sprintf("monitors[%d]", i)
+		} else if seen[name] {
+			fail("monitor %q: duplicate name", name)
+		}
+		seen[name] = true
+
+		for _, err := range validateMonitor(m) {
+			fail("monitor %q: %w", name, err)
+		}
+		if cfg.Historical.ServerSideCounts && m.Source != "datadog" {
+			fail("monitor %q: historical.serverSideCounts is only supported by the datadog source", name)
+		}
+		used[m.Source] = true
+	}
+
+	for _, err := range validateSources(cfg.Sources, used) {
+		errs = append(errs, err)
+	}
+	for _, err := range validateListeners(cfg, used) {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateMonitor(m Monitor) []error {
+	var errs []error
+
+	if m.Query == "" {
+		errs = append(errs, fmt.Errorf("query must not be empty"))
+	}
+	if !slices.Contains(SourceKinds, m.Source) {
+		errs = append(errs, fmt.Errorf("unknown source %q, must be one of %v", m.Source, SourceKinds))
+	}
+	if !aggregator.ValidLogSeverities.Includes(m.Severity) {
+		errs = append(errs, fmt.Errorf("unknown severity %q, must be one of ALL, MEDIUM or SEVERE", m.Severity))
+	}
+
+	interval, err := ingestor.ParseInterval(m.TimeInterval)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("timeInterval: %w", err))
+	}
+	historical, err := ingestor.ParseInterval(m.HistoricalTimeInterval)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("historicalTimeInterval: %w", err))
+	}
+	if interval > 0 && historical > 0 && historical <= interval {
+		errs = append(errs, fmt.Errorf("historicalTimeInterval (%s) must be greater than timeInterval (%s)", historical, interval))
+	}
+
+	if m.Schedule != "" {
+		if _, err := aggregator.ParseSchedule(m.Schedule, m.ScheduleTimezone); err != nil {
+			errs = append(errs, err)
+		}
+	} else if m.ScheduleTimezone != "" {
+		errs = append(errs, fmt.Errorf("scheduleTimezone is set without a schedule"))
+	}
+
+	if !slices.Contains(aggregator.OverlapPolicies, m.Overlap) {
+		errs = append(errs, fmt.Errorf("unknown overlap %q, must be one of %v", m.Overlap, aggregator.OverlapPolicies))
+	}
+	if m.CycleTimeout != "" {
+		if timeout, err := time.ParseDuration(m.CycleTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("cycleTimeout: %w", err))
+		} else if timeout <= 0 {
+			errs = append(errs, fmt.Errorf("cycleTimeout must be positive"))
+		}
+	}
+
+	for _, d := range m.Dimensions {
+		if d == "" {
+			errs = append(errs, fmt.Errorf("dimensions must not contain empty names"))
+		}
+	}
+
+	if len(m.Destinations) == 0 {
+		errs = append(errs, fmt.Errorf("no destinations: set slack.channelId (SLACK_CHANNEL_ID) or add one to the monitor"))
+	}
+	for _, d := range m.Destinations {
+		if d.Type != DestinationSlack {
+			errs = append(errs, fmt.Errorf("unknown destination type %q", d.Type))
+		} else if d.Channel == "" {
+			errs = append(errs, fmt.Errorf("slack destination requires a channel"))
+		}
+	}
+
+	return errs
+}
+
+func validateSources(s SourcesConfig, used map[string]bool) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	nonNegative := func(name string, v int) {
+		if v < 0 {
+			fail("%s must not be negative, got %d", name, v)
+		}
+	}
+
+	if used["datadog"] {
+		dd := s.DataDog
+		if dd.APIKey == "" || dd.ApplicationKey == "" {
+			fail("sources.datadog.apiKey and applicationKey (DD_API_KEY, DD_APPLICATION_KEY) are required")
+		}
+		if dd.Site != "" && !ingestor.IsValidDataDogSite(dd.Site) {
+			fail("sources.datadog.site: %q must be one of %v", dd.Site, ingestor.ValidDataDogSites)
+		}
+		if dd.PageLimit < 0 || dd.PageLimit > ingestor.MaxDataDogPageLimit {
+			fail("sources.datadog.pageLimit must be between 0 (API default) and %d, got %d", ingestor.MaxDataDogPageLimit, dd.PageLimit)
+		}
+		nonNegative("sources.datadog.maxLogs", dd.MaxLogs)
+		nonNegative("sources.datadog.maxPages", dd.MaxPages)
+		nonNegative("sources.datadog.retry.maxAttempts", dd.Retry.MaxAttempts)
+		for name, v := range map[string]string{"initialBackoff": dd.Retry.InitialBackoff, "maxBackoff": dd.Retry.MaxBackoff} {
+			if v == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+				fail("sources.datadog.retry.%s: %q is not a positive duration", name, v)
+			}
+		}
+	}
+
+	if used["file"] {
+		f := s.File
+		if f.Path == "" {
+			fail("sources.file.path (FILE_PATH) is required")
+		}
+		switch f.Format {
+		case ingestor.FileFormatJSON:
+		case ingestor.FileFormatText:
+			if f.LinePattern == "" {
+				fail("sources.file.linePattern (FILE_LINE_PATTERN) is required for the text format")
+			} else {
+				fields := ingestor.DefaultFieldMapping()
+				if f.TimestampField != "" {
+					fields.Timestamp = f.TimestampField
+				}
+				if _, err := ingestor.NewRegexLineParser(f.LinePattern, fields, f.TimestampLayout); err != nil {
+					fail("sources.file.linePattern: %w", err)
+				}
+			}
+		default:
+			fail("sources.file.format: unknown format %q, must be json or text", f.Format)
+		}
+	}
+
+	if used["loki"] {
+		if s.Loki.URL == "" {
+			fail("sources.loki.url (LOKI_URL) is required")
+		}
+		nonNegative("sources.loki.pageLimit", s.Loki.PageLimit)
+	}
+
+	if used[ingestor.FlavorElasticsearch] || used[ingestor.FlavorOpenSearch] {
+		if s.Elasticsearch.URL == "" || s.Elasticsearch.Index == "" {
+			fail("sources.elasticsearch.url and index (ES_URL, ES_INDEX) are required")
+		}
+		nonNegative("sources.elasticsearch.pageSize", s.Elasticsearch.PageSize)
+	}
+
+	if used["otlp"] {
+		if s.OTLP.ListenAddr == "" {
+			fail("sources.otlp.listenAddr must not be empty")
+		}
+		nonNegative("sources.otlp.bufferSize", s.OTLP.BufferSize)
+	}
+
+	if used["syslog"] {
+		nonNegative("sources.syslog.bufferSize", s.Syslog.BufferSize)
+	}
+
+	return errs
+}
+
+type listener struct {
+	name, network, addr string
+}
+
+// validateListeners rejects two listeners that would bind the same port,
+// which would otherwise fail only once the second one starts.
+func validateListeners(cfg Config, used map[string]bool) []error {
+	var listeners []listener
+	if cfg.Admin.ListenAddr != "" {
+		listeners = append(listeners, listener{"admin.listenAddr", "tcp", cfg.Admin.ListenAddr})
+	}
+	if used["otlp"] && cfg.Sources.OTLP.ListenAddr != "" {
+		listeners = append(listeners, listener{"sources.otlp.listenAddr", "tcp", cfg.Sources.OTLP.ListenAddr})
+	}
+	if used["syslog"] {
+		udp, tcp := cfg.Sources.Syslog.Addrs()
+		if tcp != "" {
+			listeners = append(listeners, listener{"sources.syslog.tcpAddr", "tcp", tcp})
+		}
+		if udp != "" {
+			listeners = append(listeners, listener{"sources.syslog.udpAddr", "udp", udp})
+		}
+	}
+
+	var errs []error
+	for i, a := range listeners {
+		for _, b := range listeners[:i] {
+			if a.network == b.network && sameListenAddr(a.addr, b.addr) {
+				errs = append(errs, fmt.Errorf("%s: %q is already used by %s", a.name, a.addr, b.name))
+			}
+		}
+	}
+	return errs
+}
+
+// sameListenAddr reports whether a and b bind the same port. An empty or
+// unspecified host listens on every interface, so it overlaps any other.
+func sameListenAddr(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	if portA != portB {
+		return false
+	}
+	anyHost := func(h string) bool { return h == "" || h == "0.0.0.0" || h == "::" }
+	return hostA == hostB || anyHost(hostA) || anyHost(hostB)
+}
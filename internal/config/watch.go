@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Watch polls the given files every interval and sends on the returned
+// channel whenever one of them is modified, created or removed. Empty paths
+// are ignored.
+func Watch(ctx context.Context, interval time.Duration, paths ...string) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	stat := func() map[string]fileState {
+		states := make(map[string]fileState, len(paths))
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				states[path] = fileState{}
+				continue
+			}
+			states[path] = fileState{exists: true, modTime: info.ModTime(), size: info.Size()}
+		}
+		return states
+	}
+
+	go func() {
+		last := stat()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := stat()
+				for path, state := range current {
+					if state != last[path] {
+						log.Info().Str("path", path).Msg("Configuration file changed")
+						select {
+						case changes <- struct{}{}:
+						default:
+						}
+						break
+					}
+				}
+				last = current
+			}
+		}
+	}()
+
+	return changes
+}
+
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, "lumberjack.yaml", "ingestionDelay: 0s\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := Watch(ctx, 10*time.Millisecond, path, "")
+
+	select {
+	case <-changes:
+		t.Fatal("unexpected change before the file was modified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("ingestionDelay: 1m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("expected a change after the file was modified")
+	}
+}
@@ -0,0 +1,177 @@
+package fixture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+)
+
+const (
+	settingsFile = "settings.json"
+	sourceFile   = "source.json"
+	resultFile   = "result.json"
+	analysisFile = "analysis.json"
+)
+
+const (
+	MethodFetch  = "fetch"
+	MethodCount  = "count"
+	MethodSample = "sample"
+)
+
+// Cycle is everything one aggregation cycle read and produced, stored as a
+// directory of JSON files so it can be inspected by hand.
+type Cycle struct {
+	Settings Settings
+	Fetches  []Fetch
+	Result   aggregator.AggregationResult
+	Analysis *analyzer.AnalysisResult
+}
+
+// Settings holds the parts of an AggregationConfig that affect a cycle's
+// result.
+type Settings struct {
+	Monitor                string        `json:"monitor"`
+	Query                  string        `json:"query"`
+	LogSeverity            string        `json:"logSeverity"`
+	Dimensions             []string      `json:"dimensions,omitempty"`
+	TimeInterval           time.Duration `json:"timeInterval"`
+	HistoricalTimeInterval time.Duration `json:"historicalTimeInterval"`
+	ServerSideHistorical   bool          `json:"serverSideHistorical,omitempty"`
+	HistoricalSampleSize   int           `json:"historicalSampleSize,omitempty"`
+	SimilarityThreshold    float64       `json:"similarityThreshold,omitempty"`
+	Baseline               bool          `json:"baseline,omitempty"`
+}
+
+// Fetch is one call made to the log source and what it returned.
+type Fetch struct {
+	Method          string                            `json:"method"`
+	Range           ingestor.AbsoluteRange            `json:"range"`
+	Query           string                            `json:"query"`
+	Dimensions      []string                          `json:"dimensions,omitempty"`
+	Interval        time.Duration                     `json:"interval,omitempty"`
+	ExcludeStatuses []string                          `json:"excludeStatuses,omitempty"`
+	Limit           int                               `json:"limit,omitempty"`
+	Records         []ingestor.LogRecord              `json:"records,omitempty"`
+	Counts          map[string][]ingestor.CountBucket `json:"counts,omitempty"`
+	Truncated       bool                              `json:"truncated,omitempty"`
+	Error           string                            `json:"error,omitempty"`
+}
+
+func SettingsFor(cfg aggregator.AggregationConfig) Settings {
+	return Settings{
+		Monitor:                cfg.Name,
+		Query:                  cfg.Query,
+		LogSeverity:            cfg.LogSeverity,
+		Dimensions:             cfg.Dimensions,
+		TimeInterval:           cfg.TimeInterval,
+		HistoricalTimeInterval: cfg.HistoricalTimeInterval,
+		ServerSideHistorical:   cfg.ServerSideHistorical,
+		HistoricalSampleSize:   cfg.HistoricalSampleSize,
+		SimilarityThreshold:    cfg.SimilarityThreshold,
+		Baseline:               cfg.Baseline != nil,
+	}
+}
+
+// Write stores c in a new directory named after its monitor and window end
+// under dir and returns its path.
+func Write(dir string, c Cycle) (string, error) {
+	path := filepath.Join(dir, c.Settings.Monitor, c.Result.Window.To.UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", err
+	}
+
+	files := map[string]any{
+		settingsFile: c.Settings,
+		sourceFile:   c.Fetches,
+		resultFile:   c.Result,
+	}
+	if c.Analysis != nil {
+		files[analysisFile] = c.Analysis
+	} else if err := os.Remove(filepath.Join(path, analysisFile)); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for name, v := range files {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(path, name), data, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// Load reads a cycle written by Write. The analysis is optional.
+func Load(path string) (Cycle, error) {
+	var c Cycle
+	files := map[string]any{
+		settingsFile: &c.Settings,
+		sourceFile:   &c.Fetches,
+		resultFile:   &c.Result,
+	}
+	for name, v := range files {
+		if err := readJSON(filepath.Join(path, name), v); err != nil {
+			return Cycle{}, err
+		}
+	}
+
+	err := readJSON(filepath.Join(path, analysisFile), &c.Analysis)
+	if err != nil && !os.IsNotExist(err) {
+		return Cycle{}, err
+	}
+	return c, nil
+}
+
+// IsCycle reports whether path is a directory written by Write.
+func IsCycle(path string) bool {
+	_, err := os.Stat(filepath.Join(path, resultFile))
+	return err == nil
+}
+
+// Replay reruns c's aggregation against its recorded source calls, using
+// the schema the original cycle resolved so results are comparable.
+func Replay(ctx context.Context, c Cycle) (aggregator.AggregationResult, error) {
+	if c.Settings.Baseline {
+		return aggregator.AggregationResult{}, fmt.Errorf("cycle for %q used the historical baseline, which only records newly fetched intervals and cannot be replayed", c.Settings.Monitor)
+	}
+
+	cache := schema.NewCacheWithSampleSize(math.MaxInt, schema.DefaultSampleSize)
+	cache.Seed(c.Result.Schema)
+
+	cfg := aggregator.AggregationConfig{
+		Name:                   c.Settings.Monitor,
+		Dimensions:             c.Settings.Dimensions,
+		Source:                 NewSource(c.Fetches),
+		TimeInterval:           c.Settings.TimeInterval,
+		Query:                  c.Settings.Query,
+		LogSeverity:            c.Settings.LogSeverity,
+		HistoricalTimeInterval: c.Settings.HistoricalTimeInterval,
+		SchemaCache:            cache,
+		ServerSideHistorical:   c.Settings.ServerSideHistorical,
+		HistoricalSampleSize:   c.Settings.HistoricalSampleSize,
+		SimilarityThreshold:    c.Settings.SimilarityThreshold,
+	}
+	return aggregator.RunCycle(ctx, cfg, c.Result.Window.To)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package fixture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+)
+
+type stubSource struct {
+	records []ingestor.LogRecord
+}
+
+func (s *stubSource) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	var out []ingestor.LogRecord
+	for _, r := range s.records {
+		if !r.Timestamp.Before(tr.Start()) && r.Timestamp.Before(tr.End()) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	end := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	src := &stubSource{records: []ingestor.LogRecord{
+		{Status: "error", Service: "api", Message: "timeout talking to db", Timestamp: end.Add(-5 * time.Minute)},
+		{Status: "error", Service: "api", Message: "timeout talking to db", Timestamp: end.Add(-4 * time.Minute)},
+		{Status: "error", Service: "billing", Message: "card declined", Timestamp: end.Add(-3 * time.Hour)},
+	}}
+
+	recorder := NewRecorder(t.TempDir())
+	cfg := aggregator.AggregationConfig{
+		Name:                   "api",
+		Dimensions:             []string{"service"},
+		TimeInterval:           15 * time.Minute,
+		HistoricalTimeInterval: 24 * time.Hour,
+		LogSeverity:            "ALL",
+		SchemaCache:            schema.NewCache(10),
+	}
+	cfg.Source = recorder.Source(cfg, src)
+
+	want, err := aggregator.RunCycle(context.Background(), cfg, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis := &analyzer.AnalysisResult{Monitor: "api", SendSummary: true, SignalStrength: 8, Severity: "high"}
+	path, err := recorder.Record(want, analysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Fetches) == 0 {
+		t.Fatal("expected recorded fetches")
+	}
+	if c.Analysis == nil || !c.Analysis.SendSummary || c.Analysis.Severity != "high" {
+		t.Errorf("analysis: got %+v", c.Analysis)
+	}
+
+	got, err := Replay(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("replayed result differs:\ngot  %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	// Recording the window again only keeps the new cycle's calls.
+	if _, err := recorder.Record(want, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Fetches) != 0 || c.Analysis != nil {
+		t.Errorf("after recording again: got %d fetches and analysis %+v", len(c.Fetches), c.Analysis)
+	}
+}
+
+type failingSource struct{}
+
+func (failingSource) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestRecorder_OverlappingCycles(t *testing.T) {
+	first := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	second := first.Add(15 * time.Minute)
+	src := &stubSource{records: []ingestor.LogRecord{
+		{Status: "error", Service: "api", Message: "timeout", Timestamp: first.Add(-time.Minute)},
+		{Status: "error", Service: "api", Message: "timeout", Timestamp: second.Add(-time.Minute)},
+	}}
+
+	recorder := NewRecorder(t.TempDir())
+	cfg := aggregator.AggregationConfig{
+		Name:                   "api",
+		TimeInterval:           15 * time.Minute,
+		HistoricalTimeInterval: time.Hour,
+		LogSeverity:            "ALL",
+		SchemaCache:            schema.NewCache(10),
+	}
+	failing := cfg
+	failing.Source = recorder.Source(cfg, failingSource{})
+	cfg.Source = recorder.Source(cfg, src)
+
+	// The second cycle runs before the first result is recorded, and a third
+	// fails in between.
+	a, err := aggregator.RunCycle(context.Background(), cfg, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := aggregator.RunCycle(context.Background(), cfg, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aggregator.RunCycle(context.Background(), failing, second.Add(15*time.Minute)); err == nil {
+		t.Fatal("expected the failing cycle to fail")
+	}
+
+	for _, want := range []aggregator.AggregationResult{a, b} {
+		path, err := recorder.Record(want, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range c.Fetches {
+			if f.Range.To.After(want.Window.To) {
+				t.Errorf("fixture for %v holds a fetch up to %v", want.Window.To, f.Range.To)
+			}
+		}
+		got, err := Replay(context.Background(), c)
+		if err != nil {
+			t.Fatalf("replay %v: %v", want.Window.To, err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("replayed result for %v differs:\ngot  %s\nwant %s", want.Window.To, gotJSON, wantJSON)
+		}
+	}
+
+	if len(recorder.pending) != 0 {
+		t.Errorf("pending after recording: got %d cycles, want none", len(recorder.pending))
+	}
+}
+
+func TestReplay_RejectsBaseline(t *testing.T) {
+	_, err := Replay(context.Background(), Cycle{Settings: Settings{Monitor: "api", Baseline: true}})
+	if err == nil {
+		t.Fatal("expected an error for a cycle that used the baseline")
+	}
+}
+
+func TestSource_Fetch(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	src := NewSource([]Fetch{
+		{Method: MethodFetch, Range: ingestor.NewAbsoluteRange(at(0), at(10)), Query: "q", Records: []ingestor.LogRecord{{Message: "a", Timestamp: at(1)}, {Message: "b", Timestamp: at(9)}}},
+		{Method: MethodFetch, Range: ingestor.NewAbsoluteRange(at(10), at(20)), Query: "q", Records: []ingestor.LogRecord{{Message: "c", Timestamp: at(15)}}, Truncated: true},
+	})
+
+	tests := []struct {
+		name      string
+		from, to  int
+		query     string
+		want      []string
+		truncated bool
+		wantErr   bool
+	}{
+		{name: "exact", from: 0, to: 10, query: "q", want: []string{"a", "b"}},
+		{name: "subrange", from: 5, to: 10, query: "q", want: []string{"b"}},
+		{name: "spans truncated page", from: 5, to: 20, query: "q", want: []string{"b", "c"}, truncated: true},
+		{name: "uncovered", from: 15, to: 30, query: "q", wantErr: true},
+		{name: "other query", from: 0, to: 10, query: "other", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := src.Fetch(context.Background(), ingestor.NewAbsoluteRange(at(tt.from), at(tt.to)), tt.query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if errors.Is(err, ingestor.ErrTruncated) != tt.truncated {
+				t.Errorf("truncated: got %v, want %v", err, tt.truncated)
+			} else if err != nil && !tt.truncated {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, r := range records {
+				got = append(got, r.Message)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("records: got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("records: got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
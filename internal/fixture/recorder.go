@@ -0,0 +1,158 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+)
+
+// Recorder captures every source call a monitor's cycles make and writes
+// them out with each cycle's result. A monitor's cycles can overlap, e.g. the
+// next one runs while the last result is analyzed, so calls are buffered per
+// cycle, by monitor and window end as set by aggregator.RunCycle. A failed
+// or cancelled cycle's calls are discarded, and calls made outside a cycle
+// are not recorded.
+type Recorder struct {
+	dir string
+
+	mu       sync.Mutex
+	settings map[string]Settings
+	pending  map[cycleKey][]Fetch
+}
+
+type cycleKey struct {
+	monitor string
+	end     int64
+}
+
+func keyOf(monitor string, end time.Time) cycleKey {
+	return cycleKey{monitor: monitor, end: end.UnixNano()}
+}
+
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{
+		dir:      dir,
+		settings: make(map[string]Settings),
+		pending:  make(map[cycleKey][]Fetch),
+	}
+}
+
+// Source wraps src so calls made for cfg's monitor are recorded.
+func (r *Recorder) Source(cfg aggregator.AggregationConfig, src ingestor.LogSource) ingestor.LogSource {
+	r.mu.Lock()
+	r.settings[cfg.Name] = SettingsFor(cfg)
+	for key := range r.pending {
+		if key.monitor == cfg.Name {
+			delete(r.pending, key)
+		}
+	}
+	r.mu.Unlock()
+
+	rs := &recordingSource{recorder: r, monitor: cfg.Name, source: src}
+	if cs, ok := src.(ingestor.CountSource); ok {
+		return &recordingCountSource{recordingSource: rs, counts: cs}
+	}
+	return rs
+}
+
+// Record writes the calls buffered for result's cycle together with result
+// and analysis, which may be nil when analysis failed.
+func (r *Recorder) Record(result aggregator.AggregationResult, analysis *analyzer.AnalysisResult) (string, error) {
+	key := keyOf(result.Monitor, result.Window.To)
+	r.mu.Lock()
+	c := Cycle{
+		Settings: r.settings[result.Monitor],
+		Fetches:  r.pending[key],
+		Result:   result,
+		Analysis: analysis,
+	}
+	// A monitor's results arrive in window order, so earlier windows still
+	// pending are results the loop dropped before delivering them.
+	for k := range r.pending {
+		if k.monitor == key.monitor && k.end <= key.end {
+			delete(r.pending, k)
+		}
+	}
+	r.mu.Unlock()
+
+	return Write(r.dir, c)
+}
+
+func (r *Recorder) add(ctx context.Context, monitor string, f Fetch, err error) {
+	cycle, ok := aggregator.CycleFrom(ctx)
+	if !ok {
+		return
+	}
+	if errors.Is(err, ingestor.ErrTruncated) {
+		f.Truncated = true
+	} else if err != nil {
+		f.Error = err.Error()
+	}
+
+	key := keyOf(monitor, cycle.WindowEnd)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pending[key]; !ok {
+		cycle.OnFailure(func() { r.discard(key) })
+	}
+	r.pending[key] = append(r.pending[key], f)
+}
+
+func (r *Recorder) discard(key cycleKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, key)
+}
+
+type recordingSource struct {
+	recorder *Recorder
+	monitor  string
+	source   ingestor.LogSource
+}
+
+func (s *recordingSource) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	records, err := s.source.Fetch(ctx, tr, query)
+	s.recorder.add(ctx, s.monitor, Fetch{
+		Method:  MethodFetch,
+		Range:   ingestor.NewAbsoluteRange(tr.Start(), tr.End()),
+		Query:   query,
+		Records: records,
+	}, err)
+	return records, err
+}
+
+type recordingCountSource struct {
+	*recordingSource
+	counts ingestor.CountSource
+}
+
+func (s *recordingCountSource) CountByDimension(ctx context.Context, tr ingestor.TimeRange, query string, dimensions []string, interval time.Duration, excludeStatuses []string) (map[string][]ingestor.CountBucket, error) {
+	counts, err := s.counts.CountByDimension(ctx, tr, query, dimensions, interval, excludeStatuses)
+	s.recorder.add(ctx, s.monitor, Fetch{
+		Method:          MethodCount,
+		Range:           ingestor.NewAbsoluteRange(tr.Start(), tr.End()),
+		Query:           query,
+		Dimensions:      dimensions,
+		Interval:        interval,
+		ExcludeStatuses: excludeStatuses,
+		Counts:          counts,
+	}, err)
+	return counts, err
+}
+
+func (s *recordingCountSource) Sample(ctx context.Context, tr ingestor.TimeRange, query string, limit int) ([]ingestor.LogRecord, error) {
+	records, err := s.counts.Sample(ctx, tr, query, limit)
+	s.recorder.add(ctx, s.monitor, Fetch{
+		Method:  MethodSample,
+		Range:   ingestor.NewAbsoluteRange(tr.Start(), tr.End()),
+		Query:   query,
+		Limit:   limit,
+		Records: records,
+	}, err)
+	return records, err
+}
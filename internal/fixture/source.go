@@ -0,0 +1,99 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+)
+
+// Source serves recorded calls in place of a live log source. Fetches are
+// answered from the exact recorded call when there is one, and otherwise
+// assembled from recorded fetches that together cover the requested range.
+type Source struct {
+	fetches []Fetch
+}
+
+func NewSource(fetches []Fetch) *Source {
+	return &Source{fetches: fetches}
+}
+
+func (s *Source) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	if f, ok := s.find(MethodFetch, tr, query); ok {
+		return f.Records, f.err()
+	}
+
+	var covering []Fetch
+	for _, f := range s.fetches {
+		if f.Method == MethodFetch && f.Query == query && f.Error == "" &&
+			f.Range.From.Before(tr.End()) && f.Range.To.After(tr.Start()) {
+			covering = append(covering, f)
+		}
+	}
+	sort.Slice(covering, func(i, j int) bool { return covering[i].Range.From.Before(covering[j].Range.From) })
+
+	var records []ingestor.LogRecord
+	truncated := false
+	covered := tr.Start()
+	for _, f := range covering {
+		if f.Range.From.After(covered) {
+			break
+		}
+		if !f.Range.To.After(covered) {
+			continue
+		}
+		for _, r := range f.Records {
+			if !r.Timestamp.Before(covered) && r.Timestamp.Before(f.Range.To) && r.Timestamp.Before(tr.End()) {
+				records = append(records, r)
+			}
+		}
+		truncated = truncated || f.Truncated
+		covered = f.Range.To
+	}
+	if covered.Before(tr.End()) {
+		return nil, fmt.Errorf("no recorded fetch covers %s to %s for query %q", covered.Format(time.RFC3339), tr.End().Format(time.RFC3339), query)
+	}
+
+	if truncated {
+		return records, ingestor.ErrTruncated
+	}
+	return records, nil
+}
+
+func (s *Source) CountByDimension(ctx context.Context, tr ingestor.TimeRange, query string, dimensions []string, interval time.Duration, excludeStatuses []string) (map[string][]ingestor.CountBucket, error) {
+	f, ok := s.find(MethodCount, tr, query)
+	if !ok {
+		return nil, fmt.Errorf("no recorded count for %s to %s for query %q", tr.Start().Format(time.RFC3339), tr.End().Format(time.RFC3339), query)
+	}
+	return f.Counts, f.err()
+}
+
+func (s *Source) Sample(ctx context.Context, tr ingestor.TimeRange, query string, limit int) ([]ingestor.LogRecord, error) {
+	f, ok := s.find(MethodSample, tr, query)
+	if !ok {
+		return nil, fmt.Errorf("no recorded sample for %s to %s for query %q", tr.Start().Format(time.RFC3339), tr.End().Format(time.RFC3339), query)
+	}
+	return f.Records, f.err()
+}
+
+func (s *Source) find(method string, tr ingestor.TimeRange, query string) (Fetch, bool) {
+	for _, f := range s.fetches {
+		if f.Method == method && f.Query == query && f.Range.From.Equal(tr.Start()) && f.Range.To.Equal(tr.End()) {
+			return f, true
+		}
+	}
+	return Fetch{}, false
+}
+
+func (f Fetch) err() error {
+	if f.Error != "" {
+		return errors.New(f.Error)
+	}
+	if f.Truncated {
+		return ingestor.ErrTruncated
+	}
+	return nil
+}
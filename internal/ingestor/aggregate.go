@@ -0,0 +1,183 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+const maxGroupValues = 100
+
+type CountBucket struct {
+	Start  time.Time      `json:"start"`
+	Values map[string]int `json:"values"`
+}
+
+type CountSource interface {
+	LogSource
+	CountByDimension(ctx context.Context, tr TimeRange, query string, dimensions []string, interval time.Duration, excludeStatuses []string) (map[string][]CountBucket, error)
+	Sample(ctx context.Context, tr TimeRange, query string, limit int) ([]LogRecord, error)
+}
+
+func (s *DataDogSource) CountByDimension(ctx context.Context, tr TimeRange, query string, dimensions []string, interval time.Duration, excludeStatuses []string) (map[string][]CountBucket, error) {
+	api := datadogV2.NewLogsApi(s.Client)
+	ddCtx := dataDogContext(ctx, s.DataDogOptions)
+
+	from := tr.Start().UTC().Format(time.RFC3339)
+	to := tr.End().UTC().Format(time.RFC3339)
+	filterQuery := withStatusExclusion(query, excludeStatuses)
+	ddInterval := dataDogInterval(interval)
+
+	counts := make(map[string][]CountBucket)
+	for _, dim := range dimensions {
+		facet := dataDogFacet(dim)
+		buckets := make(map[time.Time]map[string]int)
+		var cursor *string
+
+		for {
+			body := datadogV2.LogsAggregateRequest{
+				Compute: []datadogV2.LogsCompute{{
+					Aggregation: datadogV2.LOGSAGGREGATIONFUNCTION_COUNT,
+					Interval:    &ddInterval,
+					Type:        datadogV2.LOGSCOMPUTETYPE_TIMESERIES.Ptr(),
+				}},
+				Filter: &datadogV2.LogsQueryFilter{
+					From:  &from,
+					To:    &to,
+					Query: &filterQuery,
+				},
+				GroupBy: []datadogV2.LogsGroupBy{{
+					Facet: facet,
+					Limit: datadog64(maxGroupValues),
+				}},
+			}
+			if cursor != nil {
+				body.Page = &datadogV2.LogsAggregateRequestPage{Cursor: cursor}
+			}
+
+			var resp datadogV2.LogsAggregateResponse
+			err := s.Retry.Do(ctx, func() (*http.Response, error) {
+				var httpResp *http.Response
+				var err error
+				resp, httpResp, err = api.AggregateLogs(ddCtx, body)
+				return httpResp, err
+			})
+			if err != nil {
+				metrics.DataDogErrors.WithLabelValues(metrics.Monitor(ctx)).Inc()
+				log.Err(err).Str("facet", facet).Msg("Error when calling LogsApi.AggregateLogs")
+				return counts, err
+			}
+			metrics.DataDogPages.WithLabelValues(metrics.Monitor(ctx)).Inc()
+
+			for _, bucket := range resp.GetData().Buckets {
+				value, ok := bucket.By[facet]
+				if !ok {
+					continue
+				}
+				key := stringValue(value)
+				if dim == "status" {
+					key = strings.ToLower(key)
+				}
+				for _, compute := range bucket.Computes {
+					if compute.LogsAggregateBucketValueTimeseries == nil {
+						continue
+					}
+					for _, point := range compute.LogsAggregateBucketValueTimeseries.Items {
+						if point.Time == nil || point.Value == nil {
+							continue
+						}
+						ts, err := time.Parse(time.RFC3339, *point.Time)
+						if err != nil {
+							continue
+						}
+						if buckets[ts] == nil {
+							buckets[ts] = make(map[string]int)
+						}
+						buckets[ts][key] += int(*point.Value)
+					}
+				}
+			}
+
+			if resp.Meta == nil || resp.Meta.Page == nil || resp.Meta.Page.After == nil || *resp.Meta.Page.After == "" {
+				break
+			}
+			cursor = resp.Meta.Page.After
+		}
+
+		series := make([]CountBucket, 0, len(buckets))
+		for ts, values := range buckets {
+			series = append(series, CountBucket{Start: ts, Values: values})
+		}
+		sort.Slice(series, func(i, j int) bool {
+			return series[i].Start.Before(series[j].Start)
+		})
+		counts[dim] = series
+	}
+
+	log.Info().
+		Int("dimensions", len(dimensions)).
+		Str("interval", ddInterval).
+		Msg("Successfully retrieved log counts from DataDog")
+
+	return counts, nil
+}
+
+func (s *DataDogSource) Sample(ctx context.Context, tr TimeRange, query string, limit int) ([]LogRecord, error) {
+	opts := s.DataDogOptions
+	opts.MaxLogs = limit
+	opts.MaxPages = 0
+	if limit > 0 && (opts.PageLimit == 0 || int(opts.PageLimit) > limit) {
+		opts.PageLimit = int32(min(limit, MaxDataDogPageLimit))
+	}
+
+	logs, err := IngestFromDataDog(ctx, tr.Start(), tr.End(), s.Client, query, opts)
+	if errors.Is(err, ErrTruncated) {
+		err = nil
+	}
+	return FromDataDogLogs(logs), err
+}
+
+func withStatusExclusion(query string, statuses []string) string {
+	if len(statuses) == 0 {
+		return query
+	}
+	exclusion := fmt.Sprintf("-status:(%s)", strings.Join(statuses, " OR "))
+	if query == "" || query == "*" {
+		return exclusion
+	}
+	return fmt.Sprintf("(%s) %s", query, exclusion)
+}
+
+func dataDogFacet(dimension string) string {
+	switch dimension {
+	case "status", "host", "service", "source":
+		return dimension
+	default:
+		return "@" + dimension
+	}
+}
+
+func dataDogInterval(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", max(int64(d/time.Second), 1))
+	}
+}
+
+func datadog64(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,113 @@
+package ingestor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDataDogSource_CountByDimension(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/logs/analytics/aggregate" {
+			t.Errorf("path: got %q", r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		requests = append(requests, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if body["page"] == nil {
+			w.Write([]byte(`{"data":{"buckets":[
+				{"by":{"service":"api"},"computes":{"c0":[{"time":"2024-01-01T00:00:00Z","value":3},{"time":"2024-01-01T00:15:00Z","value":5}]}}
+			]},"meta":{"page":{"after":"next"}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"buckets":[
+			{"by":{"service":"worker"},"computes":{"c0":[{"time":"2024-01-01T00:00:00Z","value":2}]}}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	src := NewDataDogSource(newTestDataDogClient(srv.URL))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := NewAbsoluteRange(start, start.Add(30*time.Minute))
+
+	counts, err := src.CountByDimension(context.Background(), tr, "env:prod", []string{"service"}, 15*time.Minute, []string{"info", "debug"})
+	if err != nil {
+		t.Fatalf("CountByDimension: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("requests: got %d, want 2", len(requests))
+	}
+
+	filter := requests[0]["filter"].(map[string]interface{})
+	if filter["query"] != "(env:prod) -status:(info OR debug)" {
+		t.Errorf("query: got %q", filter["query"])
+	}
+	compute := requests[0]["compute"].([]interface{})[0].(map[string]interface{})
+	if compute["interval"] != "15m" || compute["type"] != "timeseries" {
+		t.Errorf("compute: got %v", compute)
+	}
+	groupBy := requests[0]["group_by"].([]interface{})[0].(map[string]interface{})
+	if groupBy["facet"] != "service" {
+		t.Errorf("facet: got %v", groupBy["facet"])
+	}
+
+	series := counts["service"]
+	if len(series) != 2 {
+		t.Fatalf("buckets: got %d, want 2", len(series))
+	}
+	if !series[0].Start.Equal(start) {
+		t.Errorf("first bucket start: got %v, want %v", series[0].Start, start)
+	}
+	if series[0].Values["api"] != 3 || series[0].Values["worker"] != 2 {
+		t.Errorf("first bucket: got %v", series[0].Values)
+	}
+	if series[1].Values["api"] != 5 {
+		t.Errorf("second bucket: got %v", series[1].Values)
+	}
+}
+
+func TestDataDogFacet(t *testing.T) {
+	tests := map[string]string{
+		"status":    "status",
+		"service":   "service",
+		"env":       "@env",
+		"http.path": "@http.path",
+	}
+	for dim, want := range tests {
+		if got := dataDogFacet(dim); got != want {
+			t.Errorf("dataDogFacet(%q): got %q, want %q", dim, got, want)
+		}
+	}
+}
+
+func TestDataDogInterval(t *testing.T) {
+	tests := map[time.Duration]string{
+		15 * time.Minute: "15m",
+		time.Hour:        "1h",
+		90 * time.Minute: "90m",
+		24 * time.Hour:   "1d",
+		30 * time.Second: "30s",
+	}
+	for d, want := range tests {
+		if got := dataDogInterval(d); got != want {
+			t.Errorf("dataDogInterval(%v): got %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestWithStatusExclusion(t *testing.T) {
+	if got := withStatusExclusion("*", []string{"info"}); got != "-status:(info)" {
+		t.Errorf("wildcard: got %q", got)
+	}
+	if got := withStatusExclusion("service:api", nil); got != "service:api" {
+		t.Errorf("no exclusion: got %q", got)
+	}
+}
@@ -0,0 +1,125 @@
+package ingestor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultBufferCapacity = 100000
+
+// MaxClockSkew is how far ahead of the clock a record may be stamped before
+// the buffer clamps its timestamp to now.
+const MaxClockSkew = time.Minute
+
+type MemoryBuffer struct {
+	mu        sync.RWMutex
+	records   []LogRecord
+	capacity  int
+	retention time.Duration
+	dropped   int
+	now       func() time.Time
+}
+
+func NewMemoryBuffer(capacity int, retention time.Duration) *MemoryBuffer {
+	if capacity <= 0 {
+		capacity = DefaultBufferCapacity
+	}
+	return &MemoryBuffer{
+		capacity:  capacity,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+func (b *MemoryBuffer) Add(records ...LogRecord) {
+	if len(records) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A sender with a skewed clock must not push its records past every
+	// window, nor make the real ones look expired.
+	now := b.now()
+	latest := now.Add(MaxClockSkew)
+	for _, r := range records {
+		if r.Timestamp.After(latest) {
+			r.Timestamp = now
+		}
+		n := len(b.records)
+		if n == 0 || !r.Timestamp.Before(b.records[n-1].Timestamp) {
+			b.records = append(b.records, r)
+			continue
+		}
+		idx := sort.Search(n, func(i int) bool {
+			return b.records[i].Timestamp.After(r.Timestamp)
+		})
+		b.records = append(b.records, LogRecord{})
+		copy(b.records[idx+1:], b.records[idx:])
+		b.records[idx] = r
+	}
+
+	b.evict(now)
+}
+
+func (b *MemoryBuffer) evict(now time.Time) {
+	drop := 0
+	if len(b.records) > b.capacity {
+		drop = len(b.records) - b.capacity
+	}
+
+	if b.retention > 0 && len(b.records) > 0 {
+		cutoff := now.Add(-b.retention)
+		expired := sort.Search(len(b.records), func(i int) bool {
+			return !b.records[i].Timestamp.Before(cutoff)
+		})
+		if expired > drop {
+			drop = expired
+		}
+	}
+
+	if drop == 0 {
+		return
+	}
+
+	b.dropped += drop
+	remaining := copy(b.records, b.records[drop:])
+	clear(b.records[remaining:])
+	b.records = b.records[:remaining]
+}
+
+func (b *MemoryBuffer) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	start, end := tr.Start(), tr.End()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	lo := sort.Search(len(b.records), func(i int) bool {
+		return !b.records[i].Timestamp.Before(start)
+	})
+	hi := sort.Search(len(b.records), func(i int) bool {
+		return !b.records[i].Timestamp.Before(end)
+	})
+	if lo >= hi {
+		return nil, nil
+	}
+
+	out := make([]LogRecord, hi-lo)
+	copy(out, b.records[lo:hi])
+	return out, nil
+}
+
+func (b *MemoryBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.records)
+}
+
+func (b *MemoryBuffer) Dropped() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.dropped
+}
@@ -0,0 +1,92 @@
+package ingestor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBuffer_FetchRange(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	b := NewMemoryBuffer(100, 0)
+	b.Add(
+		LogRecord{Timestamp: base.Add(2 * time.Minute), Message: "c"},
+		LogRecord{Timestamp: base, Message: "a"},
+		LogRecord{Timestamp: base.Add(time.Minute), Message: "b"},
+		LogRecord{Timestamp: base.Add(10 * time.Minute), Message: "d"},
+	)
+
+	records, err := b.Fetch(context.Background(), NewAbsoluteRange(base, base.Add(3*time.Minute)), "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if records[i].Message != want {
+			t.Errorf("record %d: got %q, want %q", i, records[i].Message, want)
+		}
+	}
+}
+
+func TestMemoryBuffer_Capacity(t *testing.T) {
+	base := time.Now()
+	b := NewMemoryBuffer(3, 0)
+	for i := 0; i < 5; i++ {
+		b.Add(LogRecord{Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	if b.Len() != 3 {
+		t.Errorf("Len: got %d, want 3", b.Len())
+	}
+	if b.Dropped() != 2 {
+		t.Errorf("Dropped: got %d, want 2", b.Dropped())
+	}
+}
+
+func TestMemoryBuffer_Retention(t *testing.T) {
+	base := time.Now()
+	b := NewMemoryBuffer(100, time.Hour)
+	b.Add(LogRecord{Timestamp: base.Add(-2 * time.Hour)})
+	b.Add(LogRecord{Timestamp: base.Add(-30 * time.Minute)})
+	b.Add(LogRecord{Timestamp: base})
+
+	if b.Len() != 2 {
+		t.Errorf("Len: got %d, want 2", b.Len())
+	}
+}
+
+func TestMemoryBuffer_FutureRecord(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	b := NewMemoryBuffer(100, time.Hour)
+	b.now = func() time.Time { return now }
+	b.Add(
+		LogRecord{Timestamp: now.Add(-30 * time.Minute), Message: "a"},
+		LogRecord{Timestamp: now.Add(-10 * time.Minute), Message: "b"},
+	)
+	b.Add(LogRecord{Timestamp: now.Add(time.Hour), Message: "skewed"})
+
+	if b.Len() != 3 {
+		t.Fatalf("Len: got %d, want 3", b.Len())
+	}
+	records, err := b.Fetch(context.Background(), NewAbsoluteRange(now.Add(-time.Hour), now.Add(time.Second)), "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 3 || records[2].Message != "skewed" || !records[2].Timestamp.Equal(now) {
+		t.Errorf("records: got %+v, want a, b and the skewed record clamped to now", records)
+	}
+}
+
+func TestMemoryBuffer_FetchIsHalfOpen(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	b := NewMemoryBuffer(100, 0)
+	b.Add(LogRecord{Timestamp: base.Add(15 * time.Minute), Message: "boundary"})
+
+	first, _ := b.Fetch(context.Background(), NewAbsoluteRange(base, base.Add(15*time.Minute)), "*")
+	second, _ := b.Fetch(context.Background(), NewAbsoluteRange(base.Add(15*time.Minute), base.Add(30*time.Minute)), "*")
+	if len(first) != 0 || len(second) != 1 {
+		t.Errorf("boundary record: got %d in the window ending on it and %d in the one starting on it, want 0 and 1", len(first), len(second))
+	}
+}
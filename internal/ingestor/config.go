@@ -1,15 +1,12 @@
 package ingestor
 
 import (
-	"context"
 	"os"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
 	_ "github.com/joho/godotenv/autoload"
 )
 
-var Ctx = datadog.NewDefaultContext(context.Background())
-
 func InitializeDataDog() *datadog.APIClient {
 	var configuration = datadog.NewConfiguration()
 
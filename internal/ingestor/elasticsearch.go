@@ -0,0 +1,241 @@
+package ingestor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FlavorElasticsearch = "elasticsearch"
+	FlavorOpenSearch    = "opensearch"
+)
+
+const defaultElasticsearchPageSize = 1000
+
+type ElasticsearchSource struct {
+	URL        string
+	Index      string
+	Flavor     string
+	Username   string
+	Password   string
+	APIKey     string
+	PageSize   int
+	KeepAlive  string
+	Tiebreaker string
+	Fields     FieldMapping
+	HTTPClient *http.Client
+}
+
+func NewElasticsearchSource(baseURL, index, flavor string) *ElasticsearchSource {
+	tiebreaker := "_shard_doc"
+	if flavor == FlavorOpenSearch {
+		tiebreaker = "_doc"
+	}
+	return &ElasticsearchSource{
+		URL:        strings.TrimRight(baseURL, "/"),
+		Index:      index,
+		Flavor:     flavor,
+		PageSize:   defaultElasticsearchPageSize,
+		KeepAlive:  "1m",
+		Tiebreaker: tiebreaker,
+		Fields: FieldMapping{
+			Timestamp: "@timestamp",
+			Message:   "message",
+			Status:    "log.level",
+			Host:      "host.name",
+			Service:   "service.name",
+		},
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+type esSearchResponse struct {
+	PitID string `json:"pit_id"`
+	Hits  struct {
+		Hits []esHit `json:"hits"`
+	} `json:"hits"`
+}
+
+type esHit struct {
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+	Sort   []interface{}          `json:"sort"`
+}
+
+func (s *ElasticsearchSource) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	pitID, err := s.openPointInTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := s.closePointInTime(context.WithoutCancel(ctx), pitID); err != nil {
+			log.Warn().Err(err).Msg("Failed to close point in time")
+		}
+	}()
+
+	size := s.PageSize
+	if size <= 0 {
+		size = defaultElasticsearchPageSize
+	}
+
+	start, end := tr.Start(), tr.End()
+
+	var records []LogRecord
+	var searchAfter []interface{}
+	pages := 0
+
+	for {
+		body := s.searchBody(query, start, end, pitID, size, searchAfter)
+
+		var resp esSearchResponse
+		if err := s.do(ctx, http.MethodPost, "/_search", body, &resp); err != nil {
+			return records, err
+		}
+		pages++
+
+		if resp.PitID != "" {
+			pitID = resp.PitID
+		}
+
+		for _, hit := range resp.Hits.Hits {
+			r := s.Fields.Record(hit.Source, "")
+			r.ID = hit.ID
+			r.Status = NormalizeStatus(r.Status)
+			records = append(records, r)
+		}
+
+		hits := resp.Hits.Hits
+		if len(hits) < size || len(hits[len(hits)-1].Sort) == 0 {
+			break
+		}
+		searchAfter = hits[len(hits)-1].Sort
+	}
+
+	log.Info().
+		Int("logCount", len(records)).
+		Int("pages", pages).
+		Msg("Successfully retrieved logs from Elasticsearch")
+
+	return records, nil
+}
+
+func (s *ElasticsearchSource) searchBody(query string, start, end time.Time, pitID string, size int, searchAfter []interface{}) map[string]interface{} {
+	boolQuery := map[string]interface{}{
+		"filter": []interface{}{
+			map[string]interface{}{
+				"range": map[string]interface{}{
+					s.Fields.Timestamp: map[string]interface{}{
+						"gte":    start.UTC().Format(time.RFC3339Nano),
+						"lt":     end.UTC().Format(time.RFC3339Nano),
+						"format": "strict_date_optional_time_nanos",
+					},
+				},
+			},
+		},
+	}
+	if query != "" && query != "*" {
+		boolQuery["must"] = []interface{}{
+			map[string]interface{}{
+				"query_string": map[string]interface{}{"query": query},
+			},
+		}
+	}
+
+	body := map[string]interface{}{
+		"size":  size,
+		"query": map[string]interface{}{"bool": boolQuery},
+		"pit":   map[string]interface{}{"id": pitID, "keep_alive": s.KeepAlive},
+		"sort": []interface{}{
+			map[string]interface{}{s.Fields.Timestamp: "asc"},
+			map[string]interface{}{s.Tiebreaker: "asc"},
+		},
+		"track_total_hits": false,
+	}
+	if searchAfter != nil {
+		body["search_after"] = searchAfter
+	}
+	return body
+}
+
+func (s *ElasticsearchSource) openPointInTime(ctx context.Context) (string, error) {
+	keepAlive := url.QueryEscape(s.KeepAlive)
+	index := url.PathEscape(s.Index)
+
+	if s.Flavor == FlavorOpenSearch {
+		var resp struct {
+			PitID string `json:"pit_id"`
+		}
+		if err := s.do(ctx, http.MethodPost, "/"+index+"/_search/point_in_time?keep_alive="+keepAlive, nil, &resp); err != nil {
+			return "", fmt.Errorf("failed to open point in time: %w", err)
+		}
+		return resp.PitID, nil
+	}
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/"+index+"/_pit?keep_alive="+keepAlive, nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to open point in time: %w", err)
+	}
+	return resp.ID, nil
+}
+
+func (s *ElasticsearchSource) closePointInTime(ctx context.Context, pitID string) error {
+	if s.Flavor == FlavorOpenSearch {
+		return s.do(ctx, http.MethodDelete, "/_search/point_in_time", map[string]interface{}{"pit_id": []string{pitID}}, nil)
+	}
+	return s.do(ctx, http.MethodDelete, "/_pit", map[string]interface{}{"id": pitID}, nil)
+}
+
+func (s *ElasticsearchSource) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case s.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+s.APIKey)
+	case s.Username != "":
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", s.Flavor, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", s.Flavor, err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package ingestor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchSource_SearchAfter(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	const total = 5
+
+	pitClosed := false
+	searches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/logs-*/_pit":
+			if r.URL.Query().Get("keep_alive") != "1m" {
+				t.Errorf("keep_alive: got %q", r.URL.Query().Get("keep_alive"))
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": "pit-1"})
+		case r.Method == http.MethodPost && r.URL.Path == "/_search":
+			searches++
+			var body struct {
+				Size        int               `json:"size"`
+				Pit         map[string]string `json:"pit"`
+				SearchAfter []float64         `json:"search_after"`
+				Query       map[string]map[string][]map[string]interface{}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Pit["id"] != "pit-1" {
+				t.Errorf("pit id: got %q", body.Pit["id"])
+			}
+			if len(body.Query["bool"]["must"]) != 1 {
+				t.Errorf("expected query_string clause, got %v", body.Query)
+			}
+
+			startIdx := 0
+			if len(body.SearchAfter) == 2 {
+				startIdx = int(body.SearchAfter[1]) + 1
+			}
+			var hits []map[string]interface{}
+			for i := startIdx; i < total && len(hits) < body.Size; i++ {
+				hits = append(hits, map[string]interface{}{
+					"_id": fmt.Sprintf("doc-%d", i),
+					"_source": map[string]interface{}{
+						"@timestamp": base.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+						"message":    "request failed",
+						"log":        map[string]interface{}{"level": "WARN"},
+						"host":       map[string]interface{}{"name": "web-01"},
+						"service":    map[string]interface{}{"name": "api"},
+						"env":        "prod",
+					},
+					"sort": []interface{}{base.Add(time.Duration(i) * time.Second).UnixMilli(), i},
+				})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"pit_id": "pit-1",
+				"hits":   map[string]interface{}{"hits": hits},
+			})
+		case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+			pitClosed = true
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"succeeded":true}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	src := NewElasticsearchSource(srv.URL, "logs-*", FlavorElasticsearch)
+	src.PageSize = 2
+
+	tr := NewAbsoluteRange(base, base.Add(time.Hour))
+	records, err := src.Fetch(context.Background(), tr, "service.name:api")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != total {
+		t.Fatalf("got %d records, want %d", len(records), total)
+	}
+	if searches != 3 {
+		t.Errorf("searches: got %d, want 3", searches)
+	}
+	if !pitClosed {
+		t.Error("point in time should be closed after fetching")
+	}
+
+	r := records[0]
+	if r.ID != "doc-0" || r.Status != "warning" || r.Host != "web-01" || r.Service != "api" || r.Message != "request failed" {
+		t.Errorf("field mapping: got %+v", r)
+	}
+	if !r.Timestamp.Equal(base) {
+		t.Errorf("timestamp: got %v, want %v", r.Timestamp, base)
+	}
+	if r.Attributes["env"] != "prod" {
+		t.Errorf("env attribute: got %v", r.Attributes)
+	}
+	if _, ok := r.Attributes["host"]; ok {
+		t.Error("mapped fields should not be repeated in attributes")
+	}
+}
+
+func TestElasticsearchSource_OpenSearchPointInTime(t *testing.T) {
+	var closedWith map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/logs/_search/point_in_time":
+			_ = json.NewEncoder(w).Encode(map[string]string{"pit_id": "os-pit"})
+		case r.Method == http.MethodPost && r.URL.Path == "/_search":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": []interface{}{}}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/_search/point_in_time":
+			_ = json.NewDecoder(r.Body).Decode(&closedWith)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	src := NewElasticsearchSource(srv.URL, "logs", FlavorOpenSearch)
+	records, err := src.Fetch(context.Background(), AbsoluteRange{To: time.Now()}, "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("got %d records, want 0", len(records))
+	}
+	if len(closedWith["pit_id"]) != 1 || closedWith["pit_id"][0] != "os-pit" {
+		t.Errorf("point in time close body: got %v", closedWith)
+	}
+}
+
+func TestElasticsearchSource_RangeIsHalfOpen(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	end := start.Add(15 * time.Minute)
+	src := NewElasticsearchSource("http://localhost:9200", "logs-*", FlavorElasticsearch)
+
+	body := src.searchBody("*", start, end, "", 10, nil)
+	filter := body["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+	bounds := filter[0].(map[string]interface{})["range"].(map[string]interface{})[src.Fields.Timestamp].(map[string]interface{})
+
+	if bounds["gte"] != start.Format(time.RFC3339Nano) || bounds["lt"] != end.Format(time.RFC3339Nano) {
+		t.Errorf("range: got %v, want gte start and lt end", bounds)
+	}
+	if _, ok := bounds["lte"]; ok {
+		t.Error("range should exclude its end")
+	}
+}
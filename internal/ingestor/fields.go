@@ -0,0 +1,153 @@
+package ingestor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FieldMapping struct {
+	Timestamp string
+	Message   string
+	Status    string
+	Host      string
+	Service   string
+}
+
+func DefaultFieldMapping() FieldMapping {
+	return FieldMapping{
+		Timestamp: "timestamp",
+		Message:   "message",
+		Status:    "status",
+		Host:      "host",
+		Service:   "service",
+	}
+}
+
+func (fm FieldMapping) Record(doc map[string]interface{}, layout string) LogRecord {
+	var r LogRecord
+	if v, ok := popPath(doc, fm.Timestamp); ok {
+		r.Timestamp, _ = parseTimestamp(v, layout)
+	}
+	if v, ok := popPath(doc, fm.Message); ok {
+		r.Message = stringValue(v)
+	}
+	if v, ok := popPath(doc, fm.Status); ok {
+		r.Status = stringValue(v)
+	}
+	if v, ok := popPath(doc, fm.Host); ok {
+		r.Host = stringValue(v)
+	}
+	if v, ok := popPath(doc, fm.Service); ok {
+		r.Service = stringValue(v)
+	}
+	r.Attributes = doc
+	return r
+}
+
+func popPath(m map[string]interface{}, path string) (interface{}, bool) {
+	if m == nil || path == "" {
+		return nil, false
+	}
+	if v, ok := m[path]; ok {
+		delete(m, path)
+		return v, true
+	}
+
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) == 1 {
+		return nil, false
+	}
+	nested, ok := m[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	v, ok := popPath(nested, parts[1])
+	if ok && len(nested) == 0 {
+		delete(m, parts[0])
+	}
+	return v, ok
+}
+
+func stringValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+func parseTimestamp(v interface{}, layout string) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		if layout != "" {
+			ts, err := time.Parse(layout, t)
+			return ts, err == nil
+		}
+		for _, l := range []string{time.RFC3339Nano, time.RFC3339, "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05"} {
+			if ts, err := time.Parse(l, t); err == nil {
+				return ts, true
+			}
+		}
+		if f, err := strconv.ParseFloat(t, 64); err == nil {
+			return unixTimestamp(f), true
+		}
+	case float64:
+		return unixTimestamp(t), true
+	case time.Time:
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func unixTimestamp(f float64) time.Time {
+	switch {
+	case f > 1e17:
+		return time.Unix(0, int64(f))
+	case f > 1e14:
+		return time.UnixMicro(int64(f))
+	case f > 1e11:
+		return time.UnixMilli(int64(f))
+	default:
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9))
+	}
+}
+
+func NormalizeStatus(status string) string {
+	switch s := strings.ToLower(strings.TrimSpace(status)); s {
+	case "warn":
+		return "warning"
+	case "err":
+		return "error"
+	case "dbg", "trace":
+		return "debug"
+	case "information", "notice":
+		return "info"
+	default:
+		return s
+	}
+}
+
+func setPath(m map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			if _, taken := m[part]; taken {
+				m[strings.Join(parts[i:], ".")] = value
+				return
+			}
+			next = make(map[string]interface{})
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
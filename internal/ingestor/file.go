@@ -0,0 +1,167 @@
+package ingestor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FileFormatJSON = "json"
+	FileFormatText = "text"
+)
+
+const maxLineSize = 1024 * 1024
+
+type LineParser func(line string) (LogRecord, bool)
+
+type FileSource struct {
+	Path            string
+	Format          string
+	Fields          FieldMapping
+	TimestampLayout string
+	LineParser      LineParser
+}
+
+func NewFileSource(path, format string) *FileSource {
+	return &FileSource{
+		Path:   path,
+		Format: format,
+		Fields: DefaultFieldMapping(),
+	}
+}
+
+func (s *FileSource) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	paths, err := filepath.Glob(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file path pattern %q: %w", s.Path, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match %q", s.Path)
+	}
+	sort.Strings(paths)
+
+	start, end := tr.Start(), tr.End()
+
+	var records []LogRecord
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return records, err
+		}
+
+		fileRecords, err := s.readFile(path)
+		if err != nil {
+			return records, err
+		}
+
+		for _, r := range fileRecords {
+			if r.Timestamp.Before(start) || !r.Timestamp.Before(end) {
+				continue
+			}
+			records = append(records, r)
+		}
+	}
+
+	log.Info().
+		Int("logCount", len(records)).
+		Int("files", len(paths)).
+		Msg("Successfully read logs from files")
+
+	return records, nil
+}
+
+func (s *FileSource) readFile(path string) ([]LogRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	parse := s.parseLine
+	if s.Format == FileFormatText {
+		if s.LineParser == nil {
+			return nil, fmt.Errorf("text format requires a line parser")
+		}
+		parse = s.LineParser
+	}
+
+	var records []LogRecord
+	skipped := 0
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		r, ok := parse(line)
+		if !ok || r.Timestamp.IsZero() {
+			skipped++
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return records, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if skipped > 0 {
+		log.Warn().Str("file", path).Int("skipped", skipped).Msg("Skipped unparseable log lines")
+	}
+
+	return records, nil
+}
+
+func (s *FileSource) parseLine(line string) (LogRecord, bool) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &doc); err != nil {
+		return LogRecord{}, false
+	}
+	return s.Fields.Record(doc, s.TimestampLayout), true
+}
+
+func NewRegexLineParser(pattern string, fields FieldMapping, timestampLayout string) (LineParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid line pattern: %w", err)
+	}
+
+	names := re.SubexpNames()
+	hasTimestamp := false
+	for _, name := range names {
+		if name == fields.Timestamp {
+			hasTimestamp = true
+		}
+	}
+	if !hasTimestamp {
+		return nil, fmt.Errorf("line pattern must have a named group '%s'", fields.Timestamp)
+	}
+
+	return func(line string) (LogRecord, bool) {
+		match := re.FindStringSubmatch(line)
+		if match == nil {
+			return LogRecord{}, false
+		}
+
+		doc := make(map[string]interface{})
+		for i, name := range names {
+			if name == "" || match[i] == "" {
+				continue
+			}
+			doc[name] = match[i]
+		}
+		if _, ok := doc[fields.Message]; !ok {
+			doc[fields.Message] = line
+		}
+
+		return fields.Record(doc, timestampLayout), true
+	}, nil
+}
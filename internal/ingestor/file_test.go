@@ -0,0 +1,161 @@
+package ingestor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestFileSource_JSON(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.jsonl", `{"timestamp":"2024-01-15T10:00:00Z","status":"error","host":"web-01","service":"api","message":"timeout","env":"prod"}
+{"timestamp":"2024-01-15T12:00:00Z","status":"info","host":"web-01","service":"api","message":"out of range"}
+not json
+`)
+	writeFile(t, dir, "b.jsonl", `{"timestamp":"2024-01-15T10:30:00Z","status":"warning","host":"web-02","service":"worker","message":"slow"}
+`)
+
+	fs := NewFileSource(filepath.Join(dir, "*.jsonl"), FileFormatJSON)
+	tr := NewAbsoluteRange(
+		time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC),
+	)
+
+	records, err := fs.Fetch(context.Background(), tr, "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[0].Status != "error" || records[0].Host != "web-01" || records[0].Message != "timeout" {
+		t.Errorf("first record: got %+v", records[0])
+	}
+	if records[0].Attributes["env"] != "prod" {
+		t.Errorf("env attribute: got %v", records[0].Attributes)
+	}
+	if _, ok := records[0].Attributes["status"]; ok {
+		t.Error("mapped fields should not be repeated in attributes")
+	}
+}
+
+func TestFileSource_NestedTimestampField(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "logs.jsonl", `{"event":{"created":1705312800},"message":"epoch seconds"}
+`)
+
+	fs := NewFileSource(path, FileFormatJSON)
+	fs.Fields.Timestamp = "event.created"
+	tr := NewAbsoluteRange(
+		time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+	)
+
+	records, err := fs.Fetch(context.Background(), tr, "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if _, ok := records[0].Attributes["event"]; ok {
+		t.Error("emptied parent object should be removed from attributes")
+	}
+}
+
+func TestFileSource_Text(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "app.log", `2024-01-15T10:00:00Z ERROR api connection refused
+2024-01-15T10:01:00Z INFO api request ok
+garbage line
+`)
+
+	parser, err := NewRegexLineParser(`^(?P<timestamp>\S+) (?P<status>\w+) (?P<service>\w+) (?P<message>.*)$`, DefaultFieldMapping(), "")
+	if err != nil {
+		t.Fatalf("NewRegexLineParser: %v", err)
+	}
+
+	fs := NewFileSource(path, FileFormatText)
+	fs.LineParser = parser
+	tr := NewAbsoluteRange(
+		time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+	)
+
+	records, err := fs.Fetch(context.Background(), tr, "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[0].Status != "ERROR" || records[0].Service != "api" || records[0].Message != "connection refused" {
+		t.Errorf("first record: got %+v", records[0])
+	}
+}
+
+func TestFileSource_NoMatches(t *testing.T) {
+	fs := NewFileSource(filepath.Join(t.TempDir(), "*.jsonl"), FileFormatJSON)
+	if _, err := fs.Fetch(context.Background(), AbsoluteRange{}, "*"); err == nil {
+		t.Error("expected error when no files match")
+	}
+}
+
+func TestNewRegexLineParser_RequiresTimestamp(t *testing.T) {
+	if _, err := NewRegexLineParser(`^(?P<message>.*)$`, DefaultFieldMapping(), ""); err == nil {
+		t.Error("expected error for pattern without timestamp group")
+	}
+}
+
+func TestNewRegexLineParser_TimestampField(t *testing.T) {
+	fields := DefaultFieldMapping()
+	fields.Timestamp = "ts"
+
+	if _, err := NewRegexLineParser(`^(?P<timestamp>\S+) (?P<message>.*)$`, fields, ""); err == nil {
+		t.Error("expected error for pattern without the configured timestamp group")
+	}
+
+	parser, err := NewRegexLineParser(`^(?P<ts>\S+) (?P<status>\w+) (?P<message>.*)$`, fields, "")
+	if err != nil {
+		t.Fatalf("NewRegexLineParser: %v", err)
+	}
+	r, ok := parser("2024-01-15T10:00:00Z ERROR connection refused")
+	if !ok {
+		t.Fatal("line did not match")
+	}
+	want := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	if !r.Timestamp.Equal(want) || r.Status != "ERROR" || r.Message != "connection refused" {
+		t.Errorf("got %+v, want timestamp %v", r, want)
+	}
+}
+
+func TestFileSource_FetchIsHalfOpen(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.jsonl", `{"timestamp":"2024-01-15T10:15:00Z","status":"error","message":"boundary"}
+`)
+	fs := NewFileSource(filepath.Join(dir, "*.jsonl"), FileFormatJSON)
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	first, err := fs.Fetch(context.Background(), NewAbsoluteRange(base, base.Add(15*time.Minute)), "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	second, err := fs.Fetch(context.Background(), NewAbsoluteRange(base.Add(15*time.Minute), base.Add(30*time.Minute)), "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(first) != 0 || len(second) != 1 {
+		t.Errorf("boundary record: got %d in the window ending on it and %d in the one starting on it, want 0 and 1", len(first), len(second))
+	}
+}
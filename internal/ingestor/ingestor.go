@@ -1,35 +1,95 @@
 package ingestor
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
 	"time"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
 	"github.com/rs/zerolog/log"
 )
 
-func GetIngestorFromTimeInterval(key, query string, client *datadog.APIClient) ([]datadogV2.Log, error) {
-	duration, ok := TimeIntervalToDurationMapping[key]
-	if !ok {
-		duration = FIVE_MINUTES
-	}
-	return IngestWithinTimeRange(NewDurationRange(duration), client, query)
-}
-
-func IngestWithinTimeRange(tr TimeRange, client *datadog.APIClient, query string) ([]datadogV2.Log, error) {
+func IngestWithinTimeRange(ctx context.Context, tr TimeRange, source LogSource, query string) ([]LogRecord, error) {
 	log.Info().
 		Str("query", query).
 		Str("start", tr.Start().String()).
 		Str("end", tr.End().String()).
 		Msg("Ingesting logs within time range")
-	return IngestFromDataDog(tr.Start(), tr.End(), client, query)
+	return source.Fetch(ctx, tr, query)
+}
+
+var ErrTruncated = errors.New("log fetch truncated by configured limits")
+
+var ValidDataDogSites = []string{
+	"datadoghq.com",
+	"us3.datadoghq.com",
+	"us5.datadoghq.com",
+	"ap1.datadoghq.com",
+	"datadoghq.eu",
+	"ddog-gov.com",
 }
 
-func IngestFromDataDog(from, to time.Time, client *datadog.APIClient, query string) ([]datadogV2.Log, error) {
+const MaxDataDogPageLimit = 5000
+
+type DataDogOptions struct {
+	APIKey         string
+	ApplicationKey string
+	Site           string
+	PageLimit      int32
+	MaxLogs        int
+	MaxPages       int
+	Retry          RetryPolicy
+}
+
+type DataDogSource struct {
+	Client *datadog.APIClient
+	DataDogOptions
+}
+
+func NewDataDogSource(client *datadog.APIClient) *DataDogSource {
+	return &DataDogSource{
+		Client: client,
+		DataDogOptions: DataDogOptions{
+			Retry: DefaultRetryPolicy(),
+		},
+	}
+}
+
+func (s *DataDogSource) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	logs, err := IngestFromDataDog(ctx, tr.Start(), tr.End(), s.Client, query, s.DataDogOptions)
+	return FromDataDogLogs(logs), err
+}
+
+func IsValidDataDogSite(site string) bool {
+	return slices.Contains(ValidDataDogSites, site)
+}
+
+func dataDogContext(ctx context.Context, opts DataDogOptions) context.Context {
+	ddCtx := datadog.NewDefaultContext(ctx)
+	if opts.APIKey != "" && opts.ApplicationKey != "" {
+		ddCtx = context.WithValue(ddCtx, datadog.ContextAPIKeys, map[string]datadog.APIKey{
+			"apiKeyAuth": {Key: opts.APIKey},
+			"appKeyAuth": {Key: opts.ApplicationKey},
+		})
+	}
+	if opts.Site != "" {
+		ddCtx = context.WithValue(ddCtx, datadog.ContextServerVariables, map[string]string{"site": opts.Site})
+	}
+	return ddCtx
+}
+
+func IngestFromDataDog(ctx context.Context, from, to time.Time, client *datadog.APIClient, query string, opts DataDogOptions) ([]datadogV2.Log, error) {
 	api := datadogV2.NewLogsApi(client)
+	ddCtx := dataDogContext(ctx, opts)
 
 	var allLogs []datadogV2.Log
 	var cursor *string
+	pages := 0
+	truncated := false
 
 	for {
 		params := datadogV2.NewListLogsGetOptionalParameters()
@@ -38,18 +98,30 @@ func IngestFromDataDog(from, to time.Time, client *datadog.APIClient, query stri
 		params.FilterFrom = &from
 		params.FilterTo = &to
 		params.FilterQuery = &query
+		if opts.PageLimit > 0 {
+			params.PageLimit = &opts.PageLimit
+		}
 
 		if cursor != nil {
 			params.PageCursor = cursor
 		}
 
-		resp, _, err := api.ListLogsGet(Ctx, *params)
+		var resp datadogV2.LogsListResponse
+		err := opts.Retry.Do(ctx, func() (*http.Response, error) {
+			var httpResp *http.Response
+			var err error
+			resp, httpResp, err = api.ListLogsGet(ddCtx, *params)
+			return httpResp, err
+		})
 		if err != nil {
+			metrics.DataDogErrors.WithLabelValues(metrics.Monitor(ctx)).Inc()
 			log.Err(err).Msg("Error when calling LogsApi.ListLogsGet")
 			return allLogs, err
 		}
 
 		allLogs = append(allLogs, resp.Data...)
+		pages++
+		metrics.DataDogPages.WithLabelValues(metrics.Monitor(ctx)).Inc()
 
 		if resp.Meta == nil || resp.Meta.Page == nil || resp.Meta.Page.After == nil {
 			break
@@ -59,12 +131,72 @@ func IngestFromDataDog(from, to time.Time, client *datadog.APIClient, query stri
 		if after == "" {
 			break
 		}
+
+		if opts.MaxLogs > 0 && len(allLogs) >= opts.MaxLogs {
+			allLogs = allLogs[:opts.MaxLogs]
+			truncated = true
+			break
+		}
+		if opts.MaxPages > 0 && pages >= opts.MaxPages {
+			truncated = true
+			break
+		}
 		cursor = &after
 	}
 
+	if opts.MaxLogs > 0 && len(allLogs) > opts.MaxLogs {
+		allLogs = allLogs[:opts.MaxLogs]
+		truncated = true
+	}
+
 	log.Info().
 		Int("logCount", len(allLogs)).
+		Int("pages", pages).
+		Bool("truncated", truncated).
 		Msg("Successfully retrieved logs from DataDog")
 
+	if truncated {
+		return allLogs, ErrTruncated
+	}
 	return allLogs, nil
 }
+
+func FromDataDogLogs(logs []datadogV2.Log) []LogRecord {
+	records := make([]LogRecord, 0, len(logs))
+	for _, l := range logs {
+		if l.Attributes == nil {
+			continue
+		}
+		records = append(records, FromDataDogLog(l))
+	}
+	return records
+}
+
+func FromDataDogLog(l datadogV2.Log) LogRecord {
+	var r LogRecord
+	if l.Id != nil {
+		r.ID = *l.Id
+	}
+	if l.Attributes == nil {
+		return r
+	}
+
+	attrs := l.Attributes
+	if attrs.Timestamp != nil {
+		r.Timestamp = *attrs.Timestamp
+	}
+	if attrs.Status != nil {
+		r.Status = *attrs.Status
+	}
+	if attrs.Host != nil {
+		r.Host = *attrs.Host
+	}
+	if attrs.Service != nil {
+		r.Service = *attrs.Service
+	}
+	if attrs.Message != nil {
+		r.Message = *attrs.Message
+	}
+	r.Attributes = attrs.Attributes
+	return r
+}
@@ -0,0 +1,162 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+func strPtr(s string) *string        { return &s }
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestFromDataDogLog(t *testing.T) {
+	ts := time.Now()
+	l := datadogV2.Log{
+		Id: strPtr("abc"),
+		Attributes: &datadogV2.LogAttributes{
+			Status:    strPtr("error"),
+			Host:      strPtr("web-01"),
+			Service:   strPtr("api"),
+			Message:   strPtr("connection timeout"),
+			Timestamp: timePtr(ts),
+			Attributes: map[string]interface{}{
+				"env": "production",
+			},
+		},
+	}
+
+	r := FromDataDogLog(l)
+	if r.ID != "abc" {
+		t.Errorf("ID: got %q, want 'abc'", r.ID)
+	}
+	if r.Status != "error" || r.Host != "web-01" || r.Service != "api" {
+		t.Errorf("dimensions: got %q/%q/%q", r.Status, r.Host, r.Service)
+	}
+	if r.Message != "connection timeout" {
+		t.Errorf("Message: got %q", r.Message)
+	}
+	if !r.Timestamp.Equal(ts) {
+		t.Errorf("Timestamp: got %v, want %v", r.Timestamp, ts)
+	}
+	if r.Attributes["env"] != "production" {
+		t.Errorf("Attributes: got %v", r.Attributes)
+	}
+}
+
+func TestFromDataDogLogs_SkipsNilAttributes(t *testing.T) {
+	logs := []datadogV2.Log{
+		{Attributes: nil},
+		{Attributes: &datadogV2.LogAttributes{Status: strPtr("error")}},
+	}
+
+	records := FromDataDogLogs(logs)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if records[0].Status != "error" {
+		t.Errorf("Status: got %q, want 'error'", records[0].Status)
+	}
+}
+
+func newTestDataDogClient(url string) *datadog.APIClient {
+	cfg := datadog.NewConfiguration()
+	cfg.Servers = datadog.ServerConfigurations{{URL: url}}
+	return datadog.NewAPIClient(cfg)
+}
+
+func TestDataDogSource_RetriesFailedPage(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case requests == 1:
+			w.Write([]byte(`{"data":[{"id":"1","attributes":{"status":"error","message":"first"}}],"meta":{"page":{"after":"cursor-1"}}}`))
+		case requests == 2:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"errors":["bad gateway"]}`))
+		default:
+			if r.URL.Query().Get("page[cursor]") != "cursor-1" {
+				t.Errorf("cursor: got %q", r.URL.Query().Get("page[cursor]"))
+			}
+			w.Write([]byte(`{"data":[{"id":"2","attributes":{"status":"warning","message":"second"}}],"meta":{"page":{}}}`))
+		}
+	}))
+	defer srv.Close()
+
+	src := NewDataDogSource(newTestDataDogClient(srv.URL))
+	src.Retry = fastRetryPolicy(3)
+
+	now := time.Now()
+	records, err := src.Fetch(context.Background(), NewAbsoluteRange(now.Add(-time.Hour), now), "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if requests != 3 {
+		t.Errorf("requests: got %d, want 3", requests)
+	}
+}
+
+func TestDataDogSource_MaxPagesTruncates(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("page[limit]") != "2" {
+			t.Errorf("page[limit]: got %q, want 2", r.URL.Query().Get("page[limit]"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"id":"a","attributes":{"status":"error"}},{"id":"b","attributes":{"status":"error"}}],"meta":{"page":{"after":"next"}}}`))
+	}))
+	defer srv.Close()
+
+	src := NewDataDogSource(newTestDataDogClient(srv.URL))
+	src.PageLimit = 2
+	src.MaxPages = 2
+
+	now := time.Now()
+	records, err := src.Fetch(context.Background(), NewAbsoluteRange(now.Add(-time.Hour), now), "*")
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got err %v, want ErrTruncated", err)
+	}
+	if len(records) != 4 || requests != 2 {
+		t.Errorf("got %d records in %d requests, want 4 in 2", len(records), requests)
+	}
+}
+
+func TestDataDogSource_MaxLogsTruncates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"id":"a","attributes":{}},{"id":"b","attributes":{}},{"id":"c","attributes":{}}],"meta":{"page":{"after":"next"}}}`))
+	}))
+	defer srv.Close()
+
+	src := NewDataDogSource(newTestDataDogClient(srv.URL))
+	src.MaxLogs = 2
+
+	now := time.Now()
+	records, err := src.Fetch(context.Background(), NewAbsoluteRange(now.Add(-time.Hour), now), "*")
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got err %v, want ErrTruncated", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want 2", len(records))
+	}
+}
+
+func TestIsValidDataDogSite(t *testing.T) {
+	if !IsValidDataDogSite("datadoghq.eu") {
+		t.Error("datadoghq.eu should be valid")
+	}
+	if IsValidDataDogSite("example.com") {
+		t.Error("example.com should be invalid")
+	}
+}
@@ -0,0 +1,235 @@
+package ingestor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultLokiPageLimit = 1000
+
+type LokiSource struct {
+	URL           string
+	TenantID      string
+	Username      string
+	Password      string
+	PageLimit     int
+	HostLabels    []string
+	ServiceLabels []string
+	StatusLabels  []string
+	HTTPClient    *http.Client
+}
+
+func NewLokiSource(baseURL string) *LokiSource {
+	return &LokiSource{
+		URL:           strings.TrimRight(baseURL, "/"),
+		PageLimit:     defaultLokiPageLimit,
+		HostLabels:    []string{"host", "hostname", "instance"},
+		ServiceLabels: []string{"service_name", "service", "app", "job"},
+		StatusLabels:  []string{"level", "detected_level", "severity"},
+		HTTPClient:    http.DefaultClient,
+	}
+}
+
+type lokiResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string       `json:"resultType"`
+		Result     []lokiStream `json:"result"`
+	} `json:"data"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][]string        `json:"values"`
+}
+
+type lokiEntry struct {
+	ts     time.Time
+	labels map[string]string
+	line   string
+}
+
+func (s *LokiSource) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	if query == "" || query == "*" {
+		return nil, fmt.Errorf("loki requires a LogQL stream selector query")
+	}
+
+	start, end := tr.Start(), tr.End()
+	limit := s.PageLimit
+	if limit <= 0 {
+		limit = defaultLokiPageLimit
+	}
+
+	var records []LogRecord
+	var latest time.Time
+	seen := make(map[string]struct{})
+	pages := 0
+
+	for {
+		entries, err := s.queryRange(ctx, query, start, end, limit)
+		if err != nil {
+			return records, err
+		}
+		pages++
+
+		added := 0
+		for _, e := range entries {
+			key := entryKey(e)
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			records = append(records, s.toRecord(e))
+			added++
+			if e.ts.After(latest) {
+				latest = e.ts
+				seen = make(map[string]struct{})
+			}
+			if e.ts.Equal(latest) {
+				seen[key] = struct{}{}
+			}
+		}
+
+		if len(entries) < limit {
+			break
+		}
+		if added == 0 {
+			// A full page of entries already seen: at least limit entries
+			// share the latest timestamp. Paging by time cannot get past
+			// them, so check whether there are more than were seen.
+			sameTime, err := s.queryRange(ctx, query, latest, latest.Add(time.Nanosecond), len(seen)+1)
+			if err != nil {
+				return records, err
+			}
+			pages++
+			if len(sameTime) > len(seen) {
+				log.Warn().
+					Time("timestamp", latest).
+					Int("pageLimit", limit).
+					Msg("More Loki entries share one timestamp than fit in a page, dropping the rest")
+				return records, ErrTruncated
+			}
+			start = latest.Add(time.Nanosecond)
+			continue
+		}
+		start = latest
+	}
+
+	log.Info().
+		Int("logCount", len(records)).
+		Int("pages", pages).
+		Msg("Successfully retrieved logs from Loki")
+
+	return records, nil
+}
+
+func (s *LokiSource) queryRange(ctx context.Context, query string, start, end time.Time, limit int) ([]lokiEntry, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("direction", "forward")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.TenantID)
+	}
+	if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("loki request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("loki returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var lr lokiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+		return nil, fmt.Errorf("failed to decode loki response: %w", err)
+	}
+	if lr.Data.ResultType != "" && lr.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("loki query returned %q results, expected a log query", lr.Data.ResultType)
+	}
+
+	var entries []lokiEntry
+	for _, stream := range lr.Data.Result {
+		for _, v := range stream.Values {
+			if len(v) < 2 {
+				continue
+			}
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, lokiEntry{ts: time.Unix(0, ns), labels: stream.Stream, line: v[1]})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ts.Before(entries[j].ts)
+	})
+
+	return entries, nil
+}
+
+func (s *LokiSource) toRecord(e lokiEntry) LogRecord {
+	r := LogRecord{
+		Timestamp: e.ts,
+		Message:   e.line,
+	}
+
+	used := make(map[string]struct{})
+	r.Host = firstLabel(e.labels, s.HostLabels, used)
+	r.Service = firstLabel(e.labels, s.ServiceLabels, used)
+	r.Status = NormalizeStatus(firstLabel(e.labels, s.StatusLabels, used))
+
+	for k, v := range e.labels {
+		if _, ok := used[k]; ok {
+			continue
+		}
+		if r.Attributes == nil {
+			r.Attributes = make(map[string]interface{})
+		}
+		r.Attributes[k] = v
+	}
+
+	return r
+}
+
+func firstLabel(labels map[string]string, names []string, used map[string]struct{}) string {
+	for _, name := range names {
+		if v, ok := labels[name]; ok && v != "" {
+			used[name] = struct{}{}
+			return v
+		}
+	}
+	return ""
+}
+
+func entryKey(e lokiEntry) string {
+	keys := make([]string, 0, len(e.labels))
+	for k, v := range e.labels {
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return strconv.FormatInt(e.ts.UnixNano(), 10) + "|" + strings.Join(keys, ",") + "|" + e.line
+}
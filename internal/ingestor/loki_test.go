@@ -0,0 +1,144 @@
+package ingestor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeLokiEntry struct {
+	ns     int64
+	labels map[string]string
+	line   string
+}
+
+func newFakeLoki(t *testing.T, entries []fakeLokiEntry) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/loki/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-Scope-OrgID") != "tenant-a" {
+			t.Errorf("missing tenant header")
+		}
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		var matched []fakeLokiEntry
+		for _, e := range entries {
+			if e.ns >= start && e.ns < end {
+				matched = append(matched, e)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool { return matched[i].ns < matched[j].ns })
+		if len(matched) > limit {
+			matched = matched[:limit]
+		}
+
+		var resp lokiResponse
+		resp.Status = "success"
+		resp.Data.ResultType = "streams"
+		for _, e := range matched {
+			resp.Data.Result = append(resp.Data.Result, lokiStream{
+				Stream: e.labels,
+				Values: [][]string{{strconv.FormatInt(e.ns, 10), e.line}},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestLokiSource_Pagination(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC).UnixNano()
+	labels := map[string]string{"host": "web-01", "service_name": "api", "level": "warn", "env": "prod"}
+
+	var entries []fakeLokiEntry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, fakeLokiEntry{ns: base + int64(i), labels: labels, line: "line " + strconv.Itoa(i)})
+	}
+	entries = append(entries, fakeLokiEntry{ns: base + 4, labels: labels, line: "same timestamp"})
+
+	srv, requests := newFakeLoki(t, entries)
+	src := NewLokiSource(srv.URL)
+	src.TenantID = "tenant-a"
+	src.PageLimit = 2
+
+	tr := NewAbsoluteRange(time.Unix(0, base), time.Unix(0, base+100))
+	records, err := src.Fetch(context.Background(), tr, `{service_name="api"}`)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("got %d records, want 6", len(records))
+	}
+	if *requests < 3 {
+		t.Errorf("expected multiple pages, got %d requests", *requests)
+	}
+
+	r := records[0]
+	if r.Host != "web-01" || r.Service != "api" || r.Status != "warning" {
+		t.Errorf("label mapping: got host=%q service=%q status=%q", r.Host, r.Service, r.Status)
+	}
+	if r.Attributes["env"] != "prod" {
+		t.Errorf("unmapped labels should become attributes, got %v", r.Attributes)
+	}
+	if _, ok := r.Attributes["host"]; ok {
+		t.Error("mapped labels should not be repeated in attributes")
+	}
+}
+
+func TestLokiSource_TruncatedOnOneTimestamp(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC).UnixNano()
+	labels := map[string]string{"service_name": "api"}
+
+	var entries []fakeLokiEntry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, fakeLokiEntry{ns: base, labels: labels, line: "line " + strconv.Itoa(i)})
+	}
+
+	srv, _ := newFakeLoki(t, entries)
+	src := NewLokiSource(srv.URL)
+	src.TenantID = "tenant-a"
+	src.PageLimit = 2
+
+	tr := NewAbsoluteRange(time.Unix(0, base), time.Unix(0, base+100))
+	records, err := src.Fetch(context.Background(), tr, `{service_name="api"}`)
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Fetch: got %v, want ErrTruncated", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want the first page of 2", len(records))
+	}
+}
+
+func TestLokiSource_RequiresQuery(t *testing.T) {
+	src := NewLokiSource("http://localhost")
+	if _, err := src.Fetch(context.Background(), AbsoluteRange{}, "*"); err == nil {
+		t.Error("expected error for wildcard query")
+	}
+}
+
+func TestLokiSource_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	src := NewLokiSource(srv.URL)
+	if _, err := src.Fetch(context.Background(), AbsoluteRange{}, `{job="x"}`); err == nil {
+		t.Error("expected error for non-200 response")
+	}
+}
@@ -0,0 +1,242 @@
+package ingestor
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	OTLPLogsPath       = "/v1/logs"
+	maxOTLPRequestSize = 16 * 1024 * 1024
+)
+
+type OTLPReceiver struct {
+	Buffer *MemoryBuffer
+}
+
+func NewOTLPReceiver(buffer *MemoryBuffer) *OTLPReceiver {
+	return &OTLPReceiver{Buffer: buffer}
+}
+
+func (r *OTLPReceiver) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	return r.Buffer.Fetch(ctx, tr, query)
+}
+
+func (r *OTLPReceiver) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(ctx, ln)
+}
+
+// Serve accepts OTLP/HTTP requests on ln until ctx is done, and closes ln.
+func (r *OTLPReceiver) Serve(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(OTLPLogsPath, r)
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("addr", ln.Addr().String()).Msg("Starting OTLP/HTTP logs receiver")
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (r *OTLPReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	isJSON := strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+	if !isJSON && !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-protobuf") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readOTLPBody(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var exportReq collogspb.ExportLogsServiceRequest
+	if isJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, &exportReq)
+	} else {
+		err = proto.Unmarshal(body, &exportReq)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	records := FromOTLPResourceLogs(exportReq.GetResourceLogs(), time.Now())
+	r.Buffer.Add(records...)
+
+	log.Debug().Int("logCount", len(records)).Msg("Received OTLP logs")
+
+	var resp []byte
+	if isJSON {
+		resp, err = protojson.Marshal(&collogspb.ExportLogsServiceResponse{})
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		resp, err = proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(resp)
+}
+
+func readOTLPBody(req *http.Request) ([]byte, error) {
+	var reader io.Reader = http.MaxBytesReader(nil, req.Body, maxOTLPRequestSize)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		reader = io.LimitReader(gz, maxOTLPRequestSize)
+	}
+	return io.ReadAll(reader)
+}
+
+func FromOTLPResourceLogs(resourceLogs []*logspb.ResourceLogs, received time.Time) []LogRecord {
+	var records []LogRecord
+	for _, rl := range resourceLogs {
+		resourceAttrs := rl.GetResource().GetAttributes()
+		for _, sl := range rl.GetScopeLogs() {
+			for _, lr := range sl.GetLogRecords() {
+				records = append(records, fromOTLPLogRecord(lr, resourceAttrs, received))
+			}
+		}
+	}
+	return records
+}
+
+func fromOTLPLogRecord(lr *logspb.LogRecord, resourceAttrs []*commonpb.KeyValue, received time.Time) LogRecord {
+	r := LogRecord{
+		Message: anyValueString(lr.GetBody()),
+		Status:  otlpStatus(lr.GetSeverityText(), lr.GetSeverityNumber()),
+	}
+
+	switch {
+	case lr.GetTimeUnixNano() != 0:
+		r.Timestamp = time.Unix(0, int64(lr.GetTimeUnixNano()))
+	case lr.GetObservedTimeUnixNano() != 0:
+		r.Timestamp = time.Unix(0, int64(lr.GetObservedTimeUnixNano()))
+	default:
+		r.Timestamp = received
+	}
+
+	attrs := make(map[string]interface{})
+	for _, kv := range resourceAttrs {
+		switch kv.GetKey() {
+		case "service.name":
+			r.Service = anyValueString(kv.GetValue())
+		case "host.name":
+			r.Host = anyValueString(kv.GetValue())
+		default:
+			setPath(attrs, kv.GetKey(), anyValue(kv.GetValue()))
+		}
+	}
+	for _, kv := range lr.GetAttributes() {
+		setPath(attrs, kv.GetKey(), anyValue(kv.GetValue()))
+	}
+	if len(attrs) > 0 {
+		r.Attributes = attrs
+	}
+
+	return r
+}
+
+func otlpStatus(text string, number logspb.SeverityNumber) string {
+	if text != "" {
+		return NormalizeStatus(text)
+	}
+	switch {
+	case number == logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
+		return ""
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG4:
+		return "debug"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_INFO4:
+		return "info"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_WARN4:
+		return "warning"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4:
+		return "error"
+	default:
+		return "critical"
+	}
+}
+
+func anyValue(v *commonpb.AnyValue) interface{} {
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return val.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return float64(val.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return val.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(val.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := val.ArrayValue.GetValues()
+		out := make([]interface{}, len(values))
+		for i, item := range values {
+			out[i] = anyValue(item)
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		out := make(map[string]interface{})
+		for _, kv := range val.KvlistValue.GetValues() {
+			out[kv.GetKey()] = anyValue(kv.GetValue())
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func anyValueString(v *commonpb.AnyValue) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.GetValue().(*commonpb.AnyValue_StringValue); ok {
+		return s.StringValue
+	}
+	return stringValue(anyValue(v))
+}
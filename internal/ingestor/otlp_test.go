@@ -0,0 +1,143 @@
+package ingestor
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringKV(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func TestOTLPReceiver_Protobuf(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{
+					stringKV("service.name", "api"),
+					stringKV("host.name", "web-01"),
+					stringKV("deployment.environment", "prod"),
+				},
+			},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				LogRecords: []*logspb.LogRecord{
+					{
+						TimeUnixNano: uint64(ts.UnixNano()),
+						SeverityText: "WARN",
+						Body:         &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "slow query"}},
+						Attributes:   []*commonpb.KeyValue{stringKV("db.system", "postgres")},
+					},
+					{
+						TimeUnixNano:   uint64(ts.Add(time.Second).UnixNano()),
+						SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+						Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "query failed"}},
+					},
+				},
+			}},
+		}},
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	receiver := NewOTLPReceiver(NewMemoryBuffer(100, 0))
+	httpReq := httptest.NewRequest(http.MethodPost, OTLPLogsPath, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httpReq)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	records, err := receiver.Fetch(context.Background(), NewAbsoluteRange(ts, ts.Add(time.Minute)), "*")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	r := records[0]
+	if r.Service != "api" || r.Host != "web-01" || r.Status != "warning" || r.Message != "slow query" {
+		t.Errorf("first record: got %+v", r)
+	}
+	if v := getPath(r.Attributes, "db", "system"); v != "postgres" {
+		t.Errorf("db.system attribute: got %v", r.Attributes)
+	}
+	if v := getPath(r.Attributes, "deployment", "environment"); v != "prod" {
+		t.Errorf("resource attribute: got %v", r.Attributes)
+	}
+	if records[1].Status != "error" {
+		t.Errorf("severity number mapping: got %q, want 'error'", records[1].Status)
+	}
+}
+
+func TestOTLPReceiver_JSON(t *testing.T) {
+	body := `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"worker"}}]},
+		"scopeLogs":[{"logRecords":[{"timeUnixNano":"1705312800000000000","severityText":"error","body":{"stringValue":"job failed"}}]}]}]}`
+
+	receiver := NewOTLPReceiver(NewMemoryBuffer(100, 0))
+	httpReq := httptest.NewRequest(http.MethodPost, OTLPLogsPath, bytes.NewBufferString(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httpReq)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body.String())
+	}
+	if receiver.Buffer.Len() != 1 {
+		t.Fatalf("buffer: got %d records, want 1", receiver.Buffer.Len())
+	}
+
+	ts := time.Unix(1705312800, 0)
+	records, _ := receiver.Fetch(context.Background(), NewAbsoluteRange(ts, ts.Add(time.Second)), "*")
+	if len(records) != 1 || records[0].Service != "worker" || records[0].Message != "job failed" {
+		t.Errorf("got %+v", records)
+	}
+}
+
+func TestOTLPReceiver_RejectsUnsupported(t *testing.T) {
+	receiver := NewOTLPReceiver(NewMemoryBuffer(100, 0))
+
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, OTLPLogsPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got %d, want 405", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, OTLPLogsPath, bytes.NewBufferString("x"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec = httptest.NewRecorder()
+	receiver.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain: got %d, want 415", rec.Code)
+	}
+}
+
+func getPath(m map[string]interface{}, keys ...string) interface{} {
+	var cur interface{} = m
+	for _, k := range keys {
+		next, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = next[k]
+	}
+	return cur
+}
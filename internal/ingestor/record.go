@@ -0,0 +1,23 @@
+package ingestor
+
+import (
+	"context"
+	"time"
+)
+
+type LogRecord struct {
+	ID         string                 `json:"id,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Status     string                 `json:"status,omitempty"`
+	Host       string                 `json:"host,omitempty"`
+	Service    string                 `json:"service,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// LogSource fetches the records stamped within the half-open range
+// [tr.Start(), tr.End()), so a record on a boundary belongs to exactly one of
+// two adjacent windows.
+type LogSource interface {
+	Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error)
+}
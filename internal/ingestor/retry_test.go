@@ -0,0 +1,125 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func fastRetryPolicy(attempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+func TestRetryPolicy_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	err := fastRetryPolicy(3).Do(context.Background(), func() (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable}, errors.New("unavailable")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls: got %d, want 3", calls)
+	}
+}
+
+func TestRetryPolicy_DoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	err := fastRetryPolicy(5).Do(context.Background(), func() (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusForbidden}, errors.New("forbidden")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("calls: got %d, want 1", calls)
+	}
+}
+
+func TestRetryPolicy_GivesUp(t *testing.T) {
+	calls := 0
+	err := fastRetryPolicy(2).Do(context.Background(), func() (*http.Response, error) {
+		calls++
+		return nil, errors.New("connection reset")
+	})
+	if err == nil {
+		t.Fatal("expected error after exhausting attempts")
+	}
+	if calls != 2 {
+		t.Errorf("calls: got %d, want 2", calls)
+	}
+}
+
+func TestRetryPolicy_StopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, Multiplier: 2}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- policy.Do(ctx, func() (*http.Response, error) {
+			return nil, errors.New("timeout")
+		})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Do did not return after cancel")
+	}
+}
+
+func TestRetryPolicy_DelayHonorsRateLimitReset(t *testing.T) {
+	policy := fastRetryPolicy(3)
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"X-Ratelimit-Reset": []string{"7"}},
+	}
+	if d := policy.delay(1, resp); d != 7*time.Second {
+		t.Errorf("delay: got %v, want 7s", d)
+	}
+}
+
+func TestRetryPolicy_DelayBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if d := policy.delay(i+1, nil); d != w {
+			t.Errorf("attempt %d: got %v, want %v", i+1, d, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		d := policy.delay(1, nil)
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("jittered delay out of range: %v", d)
+		}
+	}
+}
+
+func TestRetryPolicy_DelayNeverExceedsMaxBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2, Jitter: 0.5}
+	for attempt := 1; attempt <= 10; attempt++ {
+		for i := 0; i < 20; i++ {
+			if d := policy.delay(attempt, nil); d > policy.MaxBackoff {
+				t.Fatalf("attempt %d: delay %v exceeds MaxBackoff %v", attempt, d, policy.MaxBackoff)
+			}
+		}
+	}
+}
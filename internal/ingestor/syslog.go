@@ -0,0 +1,368 @@
+package ingestor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxSyslogMessageSize  = 64 * 1024
+	maxSyslogLengthDigits = 7
+)
+
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+type SyslogReceiver struct {
+	Buffer *MemoryBuffer
+}
+
+func NewSyslogReceiver(buffer *MemoryBuffer) *SyslogReceiver {
+	return &SyslogReceiver{Buffer: buffer}
+}
+
+func (r *SyslogReceiver) Fetch(ctx context.Context, tr TimeRange, query string) ([]LogRecord, error) {
+	return r.Buffer.Fetch(ctx, tr, query)
+}
+
+func (r *SyslogReceiver) ListenAndServe(ctx context.Context, network, addr string) error {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return err
+		}
+		log.Info().Str("addr", conn.LocalAddr().String()).Msg("Starting syslog UDP listener")
+		return r.ServeUDP(ctx, conn)
+	case "tcp":
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		log.Info().Str("addr", ln.Addr().String()).Msg("Starting syslog TCP listener")
+		return r.ServeTCP(ctx, ln)
+	default:
+		return fmt.Errorf("unsupported syslog network %q", network)
+	}
+}
+
+func (r *SyslogReceiver) ServeUDP(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxSyslogMessageSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		r.handle(buf[:n])
+	}
+}
+
+func (r *SyslogReceiver) ServeTCP(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			if err := r.readStream(conn); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("Syslog connection error")
+			}
+		}()
+	}
+}
+
+func (r *SyslogReceiver) readStream(rd io.Reader) error {
+	br := bufio.NewReaderSize(rd, maxSyslogMessageSize)
+	for {
+		frame, err := readSyslogFrame(br)
+		if len(frame) > 0 {
+			r.handle(frame)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (r *SyslogReceiver) handle(msg []byte) {
+	rec, err := ParseSyslog(string(msg), time.Now())
+	if err != nil {
+		log.Debug().Err(err).Msg("Dropping unparseable syslog message")
+		return
+	}
+	r.Buffer.Add(rec)
+}
+
+// readSyslogFrame supports both octet-counting and newline-delimited framing
+// (RFC 6587). A newline-delimited frame must fit in br's buffer, which
+// readStream sizes to maxSyslogMessageSize.
+func readSyslogFrame(br *bufio.Reader) ([]byte, error) {
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		var digits []byte
+		for {
+			c, err := br.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			digits = append(digits, c)
+			if c < '0' || c > '9' || len(digits) > maxSyslogLengthDigits {
+				return nil, fmt.Errorf("invalid syslog frame length %q", digits)
+			}
+		}
+		n, err := strconv.Atoi(string(digits))
+		if err != nil || n > maxSyslogMessageSize {
+			return nil, fmt.Errorf("invalid syslog frame length %q", digits)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(br, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	line, err := br.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("syslog frame longer than %d bytes", br.Size())
+	}
+	return []byte(strings.TrimRight(string(line), "\r\n\x00")), err
+}
+
+func ParseSyslog(msg string, received time.Time) (LogRecord, error) {
+	msg = strings.TrimRight(msg, "\r\n\x00")
+	pri, rest, err := parsePriority(msg)
+	if err != nil {
+		return LogRecord{}, err
+	}
+
+	var rec LogRecord
+	if strings.HasPrefix(rest, "1 ") {
+		rec, err = parseRFC5424(rest[2:], received)
+	} else {
+		rec = parseRFC3164(rest, received)
+	}
+	if err != nil {
+		return LogRecord{}, err
+	}
+
+	facility, severity := pri/8, pri%8
+	rec.Status = syslogStatus(severity)
+	if rec.Attributes == nil {
+		rec.Attributes = make(map[string]interface{})
+	}
+	setPath(rec.Attributes, "syslog.severity", syslogSeverities[severity])
+	if facility < len(syslogFacilities) {
+		setPath(rec.Attributes, "syslog.facility", syslogFacilities[facility])
+	}
+
+	return rec, nil
+}
+
+func parsePriority(msg string) (int, string, error) {
+	if !strings.HasPrefix(msg, "<") {
+		return 0, "", fmt.Errorf("missing syslog priority")
+	}
+	end := strings.IndexByte(msg, '>')
+	if end < 2 || end > 4 {
+		return 0, "", fmt.Errorf("invalid syslog priority")
+	}
+	pri, err := strconv.Atoi(msg[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", fmt.Errorf("invalid syslog priority %q", msg[1:end])
+	}
+	return pri, msg[end+1:], nil
+}
+
+func syslogStatus(severity int) string {
+	switch {
+	case severity <= 3:
+		return "error"
+	case severity == 4:
+		return "warning"
+	case severity <= 6:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+func parseRFC5424(msg string, received time.Time) (LogRecord, error) {
+	fields := strings.SplitN(msg, " ", 6)
+	if len(fields) < 6 {
+		return LogRecord{}, fmt.Errorf("truncated RFC 5424 header")
+	}
+	timestamp, hostname, appName, procID, msgID, rest := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]
+
+	rec := LogRecord{Timestamp: received}
+	if timestamp != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return LogRecord{}, fmt.Errorf("invalid RFC 5424 timestamp %q", timestamp)
+		}
+		rec.Timestamp = ts
+	}
+	rec.Host = nilValue(hostname)
+	rec.Service = nilValue(appName)
+
+	attrs := make(map[string]interface{})
+	if v := nilValue(procID); v != "" {
+		setPath(attrs, "syslog.procid", v)
+	}
+	if v := nilValue(msgID); v != "" {
+		setPath(attrs, "syslog.msgid", v)
+	}
+
+	sd, body, err := parseStructuredData(rest)
+	if err != nil {
+		return LogRecord{}, err
+	}
+	for id, params := range sd {
+		attrs[id] = params
+	}
+
+	rec.Message = strings.TrimPrefix(body, "\ufeff")
+	rec.Attributes = attrs
+	return rec, nil
+}
+
+func parseStructuredData(s string) (map[string]map[string]interface{}, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return nil, strings.TrimPrefix(s[1:], " "), nil
+	}
+
+	elements := make(map[string]map[string]interface{})
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		idEnd := strings.IndexAny(s[i:], " ]")
+		if idEnd < 0 {
+			return nil, "", fmt.Errorf("unterminated structured data")
+		}
+		id := s[i : i+idEnd]
+		i += idEnd
+		params := make(map[string]interface{})
+
+		for i < len(s) && s[i] == ' ' {
+			i++
+			eq := strings.IndexByte(s[i:], '=')
+			if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+				return nil, "", fmt.Errorf("invalid structured data parameter in %q", id)
+			}
+			name := s[i : i+eq]
+			i += eq + 2
+
+			var value strings.Builder
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, "", fmt.Errorf("unterminated structured data value in %q", id)
+			}
+			i++
+			params[name] = value.String()
+		}
+
+		if i >= len(s) || s[i] != ']' {
+			return nil, "", fmt.Errorf("unterminated structured data element %q", id)
+		}
+		i++
+		elements[id] = params
+	}
+
+	return elements, strings.TrimPrefix(s[i:], " "), nil
+}
+
+func parseRFC3164(msg string, received time.Time) LogRecord {
+	rec := LogRecord{Timestamp: received}
+
+	if len(msg) >= 16 && msg[15] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, msg[:15], received.Location()); err == nil {
+			ts = ts.AddDate(received.Year(), 0, 0)
+			if ts.After(received.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			rec.Timestamp = ts
+			msg = msg[16:]
+
+			if sp := strings.IndexByte(msg, ' '); sp > 0 && !strings.HasSuffix(msg[:sp], ":") {
+				rec.Host = msg[:sp]
+				msg = msg[sp+1:]
+			}
+		}
+	}
+
+	if colon := strings.Index(msg, ": "); colon > 0 && !strings.ContainsAny(msg[:colon], " ") {
+		tag := msg[:colon]
+		msg = msg[colon+2:]
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			rec.Attributes = map[string]interface{}{
+				"syslog": map[string]interface{}{"procid": tag[open+1 : len(tag)-1]},
+			}
+			tag = tag[:open]
+		}
+		rec.Service = tag
+	}
+
+	rec.Message = msg
+	return rec
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
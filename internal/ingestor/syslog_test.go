@@ -0,0 +1,203 @@
+package ingestor
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog_RFC5424(t *testing.T) {
+	msg := `<165>1 2024-01-15T10:00:00.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application" note="say \"hi\""] An application event`
+
+	rec, err := ParseSyslog(msg, time.Now())
+	if err != nil {
+		t.Fatalf("ParseSyslog: %v", err)
+	}
+	if rec.Status != "info" {
+		t.Errorf("Status: got %q, want 'info' (notice)", rec.Status)
+	}
+	if rec.Host != "mymachine.example.com" || rec.Service != "evntslog" {
+		t.Errorf("host/service: got %q/%q", rec.Host, rec.Service)
+	}
+	if rec.Message != "An application event" {
+		t.Errorf("Message: got %q", rec.Message)
+	}
+	if !rec.Timestamp.Equal(time.Date(2024, 1, 15, 10, 0, 0, 3000000, time.UTC)) {
+		t.Errorf("Timestamp: got %v", rec.Timestamp)
+	}
+
+	sd, ok := rec.Attributes["exampleSDID@32473"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("structured data missing: %v", rec.Attributes)
+	}
+	if sd["eventSource"] != "Application" || sd["note"] != `say "hi"` {
+		t.Errorf("structured data params: got %v", sd)
+	}
+	if v := getPath(rec.Attributes, "syslog", "facility"); v != "local4" {
+		t.Errorf("facility: got %v", v)
+	}
+	if v := getPath(rec.Attributes, "syslog", "msgid"); v != "ID47" {
+		t.Errorf("msgid: got %v", v)
+	}
+}
+
+func TestParseSyslog_RFC5424NilValues(t *testing.T) {
+	received := time.Now()
+	rec, err := ParseSyslog(`<11>1 - - - - - -`, received)
+	if err != nil {
+		t.Fatalf("ParseSyslog: %v", err)
+	}
+	if rec.Status != "error" {
+		t.Errorf("Status: got %q, want 'error'", rec.Status)
+	}
+	if rec.Host != "" || rec.Service != "" || rec.Message != "" {
+		t.Errorf("nil values should be empty: got %+v", rec)
+	}
+	if !rec.Timestamp.Equal(received) {
+		t.Error("missing timestamp should fall back to receive time")
+	}
+}
+
+func TestParseSyslog_RFC3164(t *testing.T) {
+	received := time.Date(2024, 10, 11, 23, 0, 0, 0, time.UTC)
+	rec, err := ParseSyslog(`<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8`, received)
+	if err != nil {
+		t.Fatalf("ParseSyslog: %v", err)
+	}
+	if rec.Status != "error" {
+		t.Errorf("Status: got %q, want 'error' (crit)", rec.Status)
+	}
+	if rec.Host != "mymachine" || rec.Service != "su" {
+		t.Errorf("host/service: got %q/%q", rec.Host, rec.Service)
+	}
+	if rec.Message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("Message: got %q", rec.Message)
+	}
+	if !rec.Timestamp.Equal(time.Date(2024, 10, 11, 22, 14, 15, 0, time.UTC)) {
+		t.Errorf("Timestamp: got %v", rec.Timestamp)
+	}
+	if v := getPath(rec.Attributes, "syslog", "procid"); v != "230" {
+		t.Errorf("procid: got %v", v)
+	}
+}
+
+func TestParseSyslog_RFC3164YearRollover(t *testing.T) {
+	received := time.Date(2025, 1, 1, 0, 0, 5, 0, time.UTC)
+	rec, err := ParseSyslog(`<12>Dec 31 23:59:59 host app: late`, received)
+	if err != nil {
+		t.Fatalf("ParseSyslog: %v", err)
+	}
+	if rec.Timestamp.Year() != 2024 {
+		t.Errorf("year: got %d, want 2024", rec.Timestamp.Year())
+	}
+	if rec.Status != "warning" {
+		t.Errorf("Status: got %q, want 'warning'", rec.Status)
+	}
+}
+
+func TestParseSyslog_Invalid(t *testing.T) {
+	for _, msg := range []string{"", "no priority", "<999>1 - - - - - -", "<13>1 not-a-time host app - - - msg"} {
+		if _, err := ParseSyslog(msg, time.Now()); err == nil {
+			t.Errorf("expected error for %q", msg)
+		}
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	stream := "25 <13>1 - host app - - - hi<14>Oct 11 22:14:15 host app: newline framed\n"
+	br := bufio.NewReader(strings.NewReader(stream))
+
+	frame, err := readSyslogFrame(br)
+	if err != nil {
+		t.Fatalf("octet-counted frame: %v", err)
+	}
+	if string(frame) != "<13>1 - host app - - - hi" {
+		t.Errorf("octet-counted frame: got %q", frame)
+	}
+
+	frame, err = readSyslogFrame(br)
+	if err != nil {
+		t.Fatalf("newline frame: %v", err)
+	}
+	if string(frame) != "<14>Oct 11 22:14:15 host app: newline framed" {
+		t.Errorf("newline frame: got %q", frame)
+	}
+}
+
+// endless sends one byte forever, never ending a frame.
+type endless byte
+
+func (e endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(e)
+	}
+	return len(p), nil
+}
+
+func TestSyslogReceiver_RejectsOverlongFrames(t *testing.T) {
+	receiver := NewSyslogReceiver(NewMemoryBuffer(100, 0))
+
+	// A newline-delimited frame and a length prefix that never end.
+	for _, stream := range []io.Reader{
+		io.MultiReader(strings.NewReader("<13>"), endless('a')),
+		endless('1'),
+	} {
+		done := make(chan error, 1)
+		go func() { done <- receiver.readStream(stream) }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("unterminated frame: expected an error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("unterminated frame was read without a limit")
+		}
+	}
+
+	if err := receiver.readStream(strings.NewReader("123456789 <13>1 - host app - - - hi")); err == nil {
+		t.Error("overlong length prefix: expected an error")
+	}
+	if receiver.Buffer.Len() != 0 {
+		t.Errorf("buffer: got %d records, want none", receiver.Buffer.Len())
+	}
+}
+
+func TestSyslogReceiver_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	receiver := NewSyslogReceiver(NewMemoryBuffer(100, 0))
+	done := make(chan error, 1)
+	go func() { done <- receiver.ServeUDP(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte(`<11>1 2024-01-15T10:00:00Z router1 bgpd - - - peer down`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for receiver.Buffer.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if receiver.Buffer.Len() != 1 {
+		t.Fatalf("buffer: got %d records, want 1", receiver.Buffer.Len())
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("ServeUDP: %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package ingestor
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -68,18 +71,84 @@ type TimeRange interface {
 	End() time.Time
 }
 
-type DurationRange struct {
-	duration time.Duration
+type AbsoluteRange struct {
+	From time.Time `json:"start"`
+	To   time.Time `json:"end"`
 }
 
-func (dr DurationRange) Start() time.Time {
-	return time.Now().Add(-dr.duration)
+func NewAbsoluteRange(start, end time.Time) AbsoluteRange {
+	return AbsoluteRange{From: start, To: end}
 }
 
-func (dr DurationRange) End() time.Time {
-	return time.Now()
+func (r AbsoluteRange) Start() time.Time {
+	return r.From
 }
 
-func NewDurationRange(d time.Duration) DurationRange {
-	return DurationRange{duration: d}
+func (r AbsoluteRange) End() time.Time {
+	return r.To
+}
+
+func (r AbsoluteRange) Duration() time.Duration {
+	return r.To.Sub(r.From)
+}
+
+// AlignedWindow returns the window of the given length ending at the most
+// recent align boundary at least delay before now. Boundaries are computed
+// in UTC, so hourly and shorter alignments land on :00, :15, :30 and so on.
+func AlignedWindow(now time.Time, length, align, delay time.Duration) AbsoluteRange {
+	end := now.Add(-delay).UTC().Truncate(align)
+	return NewAbsoluteRange(end.Add(-length), end)
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseInterval accepts one of the ValidTimeIntervals keys, a Go duration
+// such as "20m" or "1h30m", or an ISO-8601 duration such as "PT20M" or "P1D".
+func ParseInterval(s string) (time.Duration, error) {
+	for key, d := range TimeIntervalToDurationMapping {
+		if interval(key).Match(s) {
+			return d, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		d, err = parseISODuration(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: expected one of %v, a Go duration or an ISO-8601 duration", s, ValidTimeIntervals)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid interval %q: must be positive", s)
+	}
+	return d, nil
+}
+
+func parseISODuration(s string) (time.Duration, error) {
+	upper := strings.ToUpper(s)
+	m := isoDurationPattern.FindStringSubmatch(upper)
+	if m == nil || upper == "P" || strings.HasSuffix(upper, "T") {
+		return 0, fmt.Errorf("invalid ISO-8601 duration %q", s)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[5] != "" {
+		secs, err := strconv.ParseFloat(m[5], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(secs * float64(time.Second))
+	}
+	return d, nil
 }
@@ -0,0 +1,68 @@
+package ingestor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlignedWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 7, 30, 0, time.UTC)
+
+	w := AlignedWindow(now, 15*time.Minute, 15*time.Minute, 0)
+	wantStart := time.Date(2024, 1, 1, 11, 45, 0, 0, time.UTC)
+	wantEnd := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !w.From.Equal(wantStart) || !w.To.Equal(wantEnd) {
+		t.Errorf("window: got %v-%v, want %v-%v", w.From, w.To, wantStart, wantEnd)
+	}
+
+	delayed := AlignedWindow(now, 15*time.Minute, 15*time.Minute, 10*time.Minute)
+	if !delayed.To.Equal(time.Date(2024, 1, 1, 11, 45, 0, 0, time.UTC)) {
+		t.Errorf("delayed window end: got %v", delayed.To)
+	}
+
+	local := AlignedWindow(now.In(time.FixedZone("IST", 5*3600+1800)), 15*time.Minute, 15*time.Minute, 0)
+	if !local.To.Equal(wantEnd) {
+		t.Errorf("window should not depend on the local zone, got %v", local.To)
+	}
+}
+
+func TestAlignedWindow_LongerThanAlignment(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 10, 30, 0, time.UTC)
+	w := AlignedWindow(now, 20*time.Minute, time.Minute, 0)
+	if !w.From.Equal(time.Date(2024, 1, 1, 11, 50, 0, 0, time.UTC)) || !w.To.Equal(time.Date(2024, 1, 1, 12, 10, 0, 0, time.UTC)) {
+		t.Errorf("window: got %v-%v", w.From, w.To)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"FIFTEEN_MINUTES", 15 * time.Minute},
+		{"one_day", 24 * time.Hour},
+		{"20m", 20 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"PT20M", 20 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P1DT2H30S", 26*time.Hour + 30*time.Second},
+		{"pt1.5s", 1500 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		got, err := ParseInterval(tc.input)
+		if err != nil {
+			t.Errorf("ParseInterval(%q): %v", tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseInterval(%q): got %v, want %v", tc.input, got, tc.want)
+		}
+	}
+
+	for _, input := range []string{"", "P", "PT", "P1M", "-5m", "0s", "soon"} {
+		if _, err := ParseInterval(input); err == nil {
+			t.Errorf("ParseInterval(%q) should fail", input)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileLease is a Lease backed by an exclusive flock on a file, for instances
+// on one host or sharing a filesystem that supports flock. The kernel drops
+// the lock when its holder exits, so it never needs to expire and a standby
+// takes over within one retry of the leader stopping, however it stops.
+type FileLease struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileLease(path string) *FileLease {
+	return &FileLease{path: path}
+}
+
+func (l *FileLease) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		return true, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	ok, err := lockFile(f)
+	if !ok || err != nil {
+		f.Close()
+		return false, err
+	}
+
+	// The holder is written for operators; the lock is what counts.
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(holder+"\n"), 0)
+	}
+	l.file = f
+	return true, nil
+}
+
+func (l *FileLease) Release(ctx context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+	return err
+}
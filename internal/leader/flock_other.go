@@ -0,0 +1,18 @@
+//go:build !unix
+
+package leader
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoFlock = errors.New("file leases need flock, which this platform does not support")
+
+func lockFile(f *os.File) (bool, error) {
+	return false, errNoFlock
+}
+
+func unlockFile(f *os.File) error {
+	return errNoFlock
+}
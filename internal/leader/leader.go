@@ -0,0 +1,166 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BackendNone = "none"
+	BackendFile = "file"
+)
+
+var Backends = []string{BackendNone, BackendFile}
+
+// Roles an instance reports while election is enabled.
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+var ErrNotLeader = errors.New("not the leader")
+
+// Lease is held by at most one holder at a time until it expires. FileLease
+// covers instances on one host; a shared store such as a database row can
+// implement it for instances on different hosts.
+type Lease interface {
+	// Acquire takes the lease for holder, or extends it when holder already
+	// has it, for ttl, and reports whether holder has it.
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	// Release gives the lease up if holder has it.
+	Release(ctx context.Context, holder string) error
+}
+
+// Elector campaigns for a lease and runs work only while holding it.
+type Elector struct {
+	Lease  Lease
+	Holder string
+	TTL    time.Duration
+	// Retry is how often a standby tries to take the lease and the leader
+	// renews it; the default is TTL/3.
+	Retry time.Duration
+}
+
+// DefaultHolder identifies this process by host name and pid.
+func DefaultHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Run campaigns until ctx is done. Each time it takes the lease it calls
+// lead with a context that is cancelled when ctx is done or the lease can
+// no longer be renewed, and waits for lead to return before campaigning
+// again. A term whose lead returns an error gives the lease up, so another
+// instance, or this one after Retry, can try again. A standby takes over
+// at most TTL plus Retry after the leader stops renewing.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context) error) {
+	log.Info().Str("holder", e.Holder).Dur("ttl", e.TTL).Msg("Campaigning for leader lease")
+	for {
+		if e.acquire(ctx) {
+			e.term(ctx, lead)
+		}
+
+		timer := time.NewTimer(e.retry())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (e *Elector) retry() time.Duration {
+	if e.Retry > 0 {
+		return e.Retry
+	}
+	return e.TTL / 3
+}
+
+func (e *Elector) acquire(ctx context.Context) bool {
+	ok, err := e.Lease.Acquire(ctx, e.Holder, e.TTL)
+	if err != nil && ctx.Err() == nil {
+		log.Warn().Err(err).Msg("Failed to acquire leader lease")
+	}
+	return ok && err == nil
+}
+
+// term runs lead while renewing the lease. Renewal errors are tolerated
+// until the lease would expire before the next attempt, so the old leader
+// has stopped by the time a standby can take over.
+func (e *Elector) term(ctx context.Context, lead func(ctx context.Context) error) {
+	log.Info().Str("holder", e.Holder).Msg("Acquired leader lease")
+	metrics.Leader.Set(1)
+	defer metrics.Leader.Set(0)
+
+	termCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var leadErr error
+	go func() {
+		defer close(done)
+		leadErr = lead(termCtx)
+	}()
+
+	stop := func() {
+		cancel()
+		<-done
+	}
+
+	retry := e.retry()
+	expires := time.Now().Add(e.TTL)
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			e.release()
+			return
+		case <-done:
+			cancel()
+			if leadErr != nil {
+				log.Error().Err(leadErr).Msg("Leader term failed, releasing the lease")
+			}
+			e.release()
+			return
+		case <-ticker.C:
+		}
+
+		attempt := time.Now()
+		ok, err := e.Lease.Acquire(ctx, e.Holder, e.TTL)
+		switch {
+		case ok && err == nil:
+			expires = attempt.Add(e.TTL)
+			continue
+		case err != nil && time.Now().Add(retry).Before(expires):
+			log.Warn().Err(err).Time("expires", expires).Msg("Failed to renew leader lease, retrying")
+			continue
+		case err != nil:
+			log.Error().Err(err).Msg("Could not renew leader lease before it expires, stepping down")
+		default:
+			log.Warn().Msg("Leader lease was taken by another instance, stepping down")
+		}
+		stop()
+		e.release()
+		return
+	}
+}
+
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.Lease.Release(ctx, e.Holder); err != nil {
+		log.Warn().Err(err).Msg("Failed to release leader lease")
+		return
+	}
+	log.Info().Str("holder", e.Holder).Msg("Released leader lease")
+}
@@ -0,0 +1,203 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memLease is a Lease shared by electors in one process.
+type memLease struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+func (l *memLease) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.holder != "" && l.holder != holder && now.Before(l.expires) {
+		return false, nil
+	}
+	l.holder, l.expires = holder, now.Add(ttl)
+	return true, nil
+}
+
+func (l *memLease) Release(ctx context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == holder {
+		l.holder = ""
+	}
+	return nil
+}
+
+func (l *memLease) current() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holder
+}
+
+// leading records which electors are running lead.
+type leading struct {
+	mu      sync.Mutex
+	holders map[string]bool
+	overlap bool
+}
+
+func (l *leading) lead(holder string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		l.mu.Lock()
+		if len(l.holders) > 0 {
+			l.overlap = true
+		}
+		l.holders[holder] = true
+		l.mu.Unlock()
+
+		<-ctx.Done()
+
+		l.mu.Lock()
+		delete(l.holders, holder)
+		l.mu.Unlock()
+		return nil
+	}
+}
+
+func (l *leading) only() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.holders) != 1 {
+		return ""
+	}
+	for h := range l.holders {
+		return h
+	}
+	return ""
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestElector_StandbyTakesOverWhenLeaderStops(t *testing.T) {
+	lease := &memLease{}
+	l := &leading{holders: map[string]bool{}}
+
+	ctxA, stopA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	a := &Elector{Lease: lease, Holder: "a", TTL: 60 * time.Millisecond}
+	go func() {
+		defer close(doneA)
+		a.Run(ctxA, l.lead("a"))
+	}()
+	waitFor(t, "a to lead", func() bool { return l.only() == "a" })
+
+	ctxB, stopB := context.WithCancel(context.Background())
+	defer stopB()
+	b := &Elector{Lease: lease, Holder: "b", TTL: 60 * time.Millisecond}
+	go b.Run(ctxB, l.lead("b"))
+
+	// a keeps renewing, so b stays on standby.
+	time.Sleep(150 * time.Millisecond)
+	if got := l.only(); got != "a" {
+		t.Fatalf("leader while a renews: got %q, want a", got)
+	}
+
+	stopA()
+	<-doneA
+	waitFor(t, "b to take over", func() bool { return l.only() == "b" })
+	if lease.current() != "b" {
+		t.Errorf("lease holder: got %q, want b", lease.current())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.overlap {
+		t.Error("a and b led at the same time")
+	}
+}
+
+func TestElector_StepsDownWhenLeaseIsLost(t *testing.T) {
+	lease := &memLease{}
+	l := &leading{holders: map[string]bool{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := &Elector{Lease: lease, Holder: "a", TTL: time.Minute, Retry: 10 * time.Millisecond}
+	go e.Run(ctx, l.lead("a"))
+	waitFor(t, "a to lead", func() bool { return l.only() == "a" })
+
+	// Another holder takes the lease, as after a partition outlasting the TTL.
+	lease.mu.Lock()
+	lease.holder = "b"
+	lease.mu.Unlock()
+
+	waitFor(t, "a to step down", func() bool { return l.only() == "" })
+	if lease.current() != "b" {
+		t.Errorf("stepping down released b's lease: holder %q", lease.current())
+	}
+}
+
+func TestElector_CampaignsAgainAfterFailedTerm(t *testing.T) {
+	lease := &memLease{}
+	var mu sync.Mutex
+	terms := 0
+	lead := func(ctx context.Context) error {
+		mu.Lock()
+		terms++
+		n := terms
+		mu.Unlock()
+		if n == 1 {
+			return errors.New("state store unavailable")
+		}
+		<-ctx.Done()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := &Elector{Lease: lease, Holder: "a", TTL: time.Minute, Retry: 10 * time.Millisecond}
+	go e.Run(ctx, lead)
+	waitFor(t, "a second term", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return terms == 2
+	})
+	if lease.current() != "a" {
+		t.Errorf("lease holder: got %q, want a", lease.current())
+	}
+}
+
+func TestFileLease_Exclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	a, b := NewFileLease(path), NewFileLease(path)
+	ctx := context.Background()
+
+	if ok, err := a.Acquire(ctx, "a", time.Minute); !ok || err != nil {
+		t.Fatalf("a: got %v, %v, want the lease", ok, err)
+	}
+	if ok, err := a.Acquire(ctx, "a", time.Minute); !ok || err != nil {
+		t.Fatalf("a renewing: got %v, %v", ok, err)
+	}
+	if ok, err := b.Acquire(ctx, "b", time.Minute); ok || err != nil {
+		t.Fatalf("b while a holds it: got %v, %v, want false", ok, err)
+	}
+
+	if err := a.Release(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.Acquire(ctx, "b", time.Minute); !ok || err != nil {
+		t.Fatalf("b after release: got %v, %v, want the lease", ok, err)
+	}
+}
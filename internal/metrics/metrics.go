@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Cycle stages, in the order a cycle runs them.
+const (
+	StageIngest    = "ingest"
+	StageSchema    = "schema"
+	StageAggregate = "aggregate"
+	StageFuzzy     = "fuzzy"
+	StageAnalyze   = "analyze"
+	StageNotify    = "notify"
+)
+
+// Notification outcomes.
+const (
+	OutcomeSent   = "sent"
+	OutcomeFailed = "failed"
+	OutcomeDryRun = "dry_run"
+)
+
+// Registry holds Lumberjack's own metrics plus the Go runtime and process
+// collectors. It is separate from the default registry so that libraries
+// registering there don't leak into /metrics.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	StageDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lumberjack_cycle_stage_duration_seconds",
+		Help:    "Time spent in each stage of an aggregation cycle.",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"monitor", "stage"})
+
+	Cycles = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_cycles_total",
+		Help: "Aggregation cycles run, by whether they produced a result.",
+	}, []string{"monitor", "outcome"})
+
+	MissedCycles = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_cycles_missed_total",
+		Help: "Windows that were due but not delivered, by reason (skipped, cancelled, late or dropped).",
+	}, []string{"monitor", "reason"})
+
+	LastSuccess = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lumberjack_cycle_last_success_timestamp_seconds",
+		Help: "Unix time of the last aggregation cycle that produced a result.",
+	}, []string{"monitor"})
+
+	WindowLogs = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lumberjack_window_logs",
+		Help:    "Logs fetched for the current window of each cycle.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 11),
+	}, []string{"monitor"})
+
+	DataDogPages = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_datadog_pages_total",
+		Help: "Pages of logs and aggregates requested from DataDog.",
+	}, []string{"monitor"})
+
+	DataDogErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_datadog_errors_total",
+		Help: "DataDog requests that failed after retries.",
+	}, []string{"monitor"})
+
+	AnalyzerDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lumberjack_analyzer_request_duration_seconds",
+		Help:    "Latency of analyzer requests.",
+		Buckets: []float64{.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"monitor"})
+
+	AnalyzerErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_analyzer_errors_total",
+		Help: "Analyzer requests that failed or returned an unusable response.",
+	}, []string{"monitor"})
+
+	AnalyzerTokens = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_analyzer_tokens_total",
+		Help: "Tokens used by analyzer requests, by type (input or output).",
+	}, []string{"monitor", "type"})
+
+	SignalStrength = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lumberjack_signal_strength",
+		Help:    "Signal strength of each analysis, from 1 to 10.",
+		Buckets: prometheus.LinearBuckets(1, 1, 10),
+	}, []string{"monitor"})
+
+	Notifications = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_notifications_total",
+		Help: "Notifications for analyses above the alert threshold, by destination and outcome (sent, failed or dry_run).",
+	}, []string{"monitor", "destination", "outcome"})
+
+	Leader = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "lumberjack_leader",
+		Help: "1 while this instance holds the leader lease, or runs without election.",
+	})
+
+	NotificationsSuppressed = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "lumberjack_notifications_suppressed_total",
+		Help: "Analyses below the alert threshold, for which no notification was sent.",
+	}, []string{"monitor"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+type contextKey struct{}
+
+// cycle carries the monitor a context works for and the time its cycle has
+// spent in each stage so far.
+type cycle struct {
+	monitor string
+	mu      sync.Mutex
+	stages  map[string]time.Duration
+}
+
+// WithMonitor returns a context that attributes metrics recorded under it,
+// including by sources shared between monitors, to monitor. Stage times
+// recorded with Stage are summed until ObserveCycle.
+func WithMonitor(ctx context.Context, monitor string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &cycle{monitor: monitor, stages: make(map[string]time.Duration)})
+}
+
+// Monitor returns the monitor set by WithMonitor, or "" without one.
+func Monitor(ctx context.Context) string {
+	if c, ok := ctx.Value(contextKey{}).(*cycle); ok {
+		return c.monitor
+	}
+	return ""
+}
+
+// Stage starts timing stage and returns a function that stops it. A stage
+// may be timed several times in one cycle; the durations are summed.
+func Stage(ctx context.Context, stage string) func() {
+	c, ok := ctx.Value(contextKey{}).(*cycle)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.stages[stage] += elapsed
+	}
+}
+
+// ObserveCycle records the cycle's outcome and the total time it spent in
+// each stage timed with Stage.
+func ObserveCycle(ctx context.Context, err error) {
+	c, ok := ctx.Value(contextKey{}).(*cycle)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for stage, d := range c.stages {
+		StageDuration.WithLabelValues(c.monitor, stage).Observe(d.Seconds())
+	}
+	if err != nil {
+		Cycles.WithLabelValues(c.monitor, "failure").Inc()
+		return
+	}
+	Cycles.WithLabelValues(c.monitor, "success").Inc()
+	LastSuccess.WithLabelValues(c.monitor).SetToCurrentTime()
+}
+
+// ObserveStage records a stage that runs once per cycle outside the
+// aggregation itself, such as analyze and notify.
+func ObserveStage(monitor, stage string, start time.Time) {
+	StageDuration.WithLabelValues(monitor, stage).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStage_SumsPerCycle(t *testing.T) {
+	ctx := WithMonitor(context.Background(), "stage-test")
+	if got := Monitor(ctx); got != "stage-test" {
+		t.Fatalf("Monitor: got %q", got)
+	}
+
+	for range 3 {
+		stop := Stage(ctx, StageIngest)
+		time.Sleep(time.Millisecond)
+		stop()
+	}
+	Stage(ctx, StageSchema)()
+	ObserveCycle(ctx, nil)
+
+	// One observation per stage per cycle, however often it was timed.
+	if got := testutil.CollectAndCount(StageDuration.MustCurryWith(map[string]string{"monitor": "stage-test"})); got != 2 {
+		t.Errorf("stage series: got %d, want 2", got)
+	}
+	if got := testutil.ToFloat64(Cycles.WithLabelValues("stage-test", "success")); got != 1 {
+		t.Errorf("successful cycles: got %v", got)
+	}
+	if got := testutil.ToFloat64(LastSuccess.WithLabelValues("stage-test")); got == 0 {
+		t.Error("last success was not set")
+	}
+
+	ObserveCycle(WithMonitor(context.Background(), "stage-test"), errors.New("boom"))
+	if got := testutil.ToFloat64(Cycles.WithLabelValues("stage-test", "failure")); got != 1 {
+		t.Errorf("failed cycles: got %v", got)
+	}
+}
+
+func TestStage_WithoutMonitor(t *testing.T) {
+	ctx := context.Background()
+	if got := Monitor(ctx); got != "" {
+		t.Errorf("Monitor: got %q", got)
+	}
+	Stage(ctx, StageIngest)()
+	ObserveCycle(ctx, nil)
+}
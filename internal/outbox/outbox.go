@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/rs/zerolog/log"
+)
+
+const fileExt = ".json"
+
+// Outbox keeps aggregation results that were not delivered before shutdown,
+// one file each, so the next run can deliver them. A result is keyed by its
+// monitor and window, so saving it again replaces the earlier copy.
+type Outbox struct {
+	dir string
+}
+
+type Entry struct {
+	Result  aggregator.AggregationResult `json:"result"`
+	SavedAt time.Time                    `json:"savedAt"`
+
+	path string
+}
+
+func New(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+// Put saves result and returns the path it was written to.
+func (o *Outbox) Put(result aggregator.AggregationResult) (string, error) {
+	data, err := json.Marshal(Entry{Result: result, SavedAt: time.Now().UTC()})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode outbox entry: %w", err)
+	}
+
+	path := o.path(result)
+	tmp, err := os.CreateTemp(o.dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// List returns the saved entries, oldest window first. Files it cannot
+// decode are skipped and left in place.
+func (o *Outbox) List() ([]Entry, error) {
+	files, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		path := filepath.Join(o.dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Skipping unreadable outbox entry")
+			continue
+		}
+		e.path = path
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Result.Window.To.Before(entries[j].Result.Window.To)
+	})
+	return entries, nil
+}
+
+func (o *Outbox) Remove(e Entry) error {
+	err := os.Remove(e.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (o *Outbox) path(result aggregator.AggregationResult) string {
+	name := fmt.Sprintf("%s-%d%s", url.PathEscape(result.Monitor), result.Window.To.Unix(), fileExt)
+	return filepath.Join(o.dir, name)
+}
@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func resultAt(monitor string, hours int, logCount int) aggregator.AggregationResult {
+	end := base.Add(time.Duration(hours) * time.Hour)
+	return aggregator.AggregationResult{
+		Monitor:  monitor,
+		Window:   ingestor.NewAbsoluteRange(end.Add(-time.Hour), end),
+		LogCount: logCount,
+	}
+}
+
+func TestOutbox_PutListRemove(t *testing.T) {
+	dir := t.TempDir()
+	ob, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []aggregator.AggregationResult{
+		resultAt("api", 2, 1),
+		resultAt("payments/eu", 1, 1),
+		resultAt("api", 2, 5), // replaces the first
+	} {
+		if _, err := ob.Put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "torn.json"), []byte(`{"result":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ob.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries: got %d, want 2", len(entries))
+	}
+	if entries[0].Result.Monitor != "payments/eu" || entries[1].Result.Monitor != "api" {
+		t.Errorf("order: got %s, %s, want oldest window first", entries[0].Result.Monitor, entries[1].Result.Monitor)
+	}
+	if entries[1].Result.LogCount != 5 {
+		t.Errorf("api entry: got logCount %d, want the later copy", entries[1].Result.LogCount)
+	}
+
+	if err := ob.Remove(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.Remove(entries[0]); err != nil {
+		t.Errorf("removing twice: %v", err)
+	}
+	entries, err = ob.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Result.Monitor != "api" {
+		t.Errorf("after remove: got %v", entries)
+	}
+}
@@ -3,7 +3,7 @@ package schema
 import (
 	"sync"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 )
 
 type Cache struct {
@@ -11,22 +11,28 @@ type Cache struct {
 	current       *Schema
 	cycleCount    int
 	refreshEveryN int
+	sampleSize    int
 }
 
 func NewCache(refreshEveryN int) *Cache {
+	return NewCacheWithSampleSize(refreshEveryN, DefaultSampleSize)
+}
+
+func NewCacheWithSampleSize(refreshEveryN, sampleSize int) *Cache {
 	return &Cache{
 		refreshEveryN: refreshEveryN,
+		sampleSize:    sampleSize,
 	}
 }
 
-func (c *Cache) Get(logs []datadogV2.Log) Schema {
+func (c *Cache) Get(logs []ingestor.LogRecord) Schema {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.cycleCount++
 
 	if c.current == nil || c.cycleCount >= c.refreshEveryN {
-		s := Discover(logs)
+		s := DiscoverWithSampleSize(logs, c.sampleSize)
 		c.current = &s
 		c.cycleCount = 0
 	}
@@ -46,3 +52,11 @@ func (c *Cache) Invalidate() {
 	c.current = nil
 	c.cycleCount = 0
 }
+
+// Seed makes s the current schema as if it had just been discovered.
+func (c *Cache) Seed(s Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = &s
+	c.cycleCount = 0
+}
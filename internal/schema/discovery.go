@@ -4,38 +4,38 @@ import (
 	"fmt"
 	"sort"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 )
 
 const maxExamples = 5
-const maxSampleSize = 200
+const DefaultSampleSize = 200
 
-func Discover(logs []datadogV2.Log) Schema {
+func Discover(logs []ingestor.LogRecord) Schema {
+	return DiscoverWithSampleSize(logs, DefaultSampleSize)
+}
+
+func DiscoverWithSampleSize(logs []ingestor.LogRecord, sampleSize int) Schema {
 	fieldValues := make(map[string]map[string]struct{})
 	fieldTypes := make(map[string]FieldType)
 
 	sample := logs
-	if len(sample) > maxSampleSize {
-		sample = sample[:maxSampleSize]
+	if len(sample) > sampleSize {
+		sample = sample[:sampleSize]
 	}
 
 	for _, l := range sample {
-		if l.Attributes == nil {
-			continue
-		}
-
-		if l.Attributes.Status != nil {
-			trackField(fieldValues, fieldTypes, "status", *l.Attributes.Status)
+		if l.Status != "" {
+			trackField(fieldValues, fieldTypes, "status", l.Status)
 		}
-		if l.Attributes.Host != nil {
-			trackField(fieldValues, fieldTypes, "host", *l.Attributes.Host)
+		if l.Host != "" {
+			trackField(fieldValues, fieldTypes, "host", l.Host)
 		}
-		if l.Attributes.Service != nil {
-			trackField(fieldValues, fieldTypes, "service", *l.Attributes.Service)
+		if l.Service != "" {
+			trackField(fieldValues, fieldTypes, "service", l.Service)
 		}
 
-		if l.Attributes.Attributes != nil {
-			discoverMap(fieldValues, fieldTypes, "", l.Attributes.Attributes)
+		if l.Attributes != nil {
+			discoverMap(fieldValues, fieldTypes, "", l.Attributes)
 		}
 	}
 
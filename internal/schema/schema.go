@@ -36,3 +36,18 @@ func (s Schema) HasField(name string) bool {
 	}
 	return false
 }
+
+func (s Schema) Select(names []string) Schema {
+	selected := Schema{Fields: make([]Field, 0, len(names))}
+	for _, name := range names {
+		field := Field{Name: name, Type: FieldTypeUnknown}
+		for _, f := range s.Fields {
+			if f.Name == name {
+				field = f
+				break
+			}
+		}
+		selected.Fields = append(selected.Fields, field)
+	}
+	return selected
+}
@@ -5,28 +5,23 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
 )
 
-func strPtr(s string) *string        { return &s }
-func timePtr(t time.Time) *time.Time { return &t }
-
-func makeLogs(count int) []datadogV2.Log {
-	logs := make([]datadogV2.Log, count)
+func makeLogs(count int) []ingestor.LogRecord {
+	logs := make([]ingestor.LogRecord, count)
 	for i := range logs {
-		logs[i] = datadogV2.Log{
-			Attributes: &datadogV2.LogAttributes{
-				Status:    strPtr("error"),
-				Host:      strPtr("web-01"),
-				Service:   strPtr("api"),
-				Message:   strPtr("something failed"),
-				Timestamp: timePtr(time.Now()),
-				Attributes: map[string]interface{}{
-					"env":    "production",
-					"region": "us-east-1",
-					"nested": map[string]interface{}{
-						"level": "deep",
-					},
+		logs[i] = ingestor.LogRecord{
+			Status:    "error",
+			Host:      "web-01",
+			Service:   "api",
+			Message:   "something failed",
+			Timestamp: time.Now(),
+			Attributes: map[string]interface{}{
+				"env":    "production",
+				"region": "us-east-1",
+				"nested": map[string]interface{}{
+					"level": "deep",
 				},
 			},
 		}
@@ -78,9 +73,9 @@ func TestDiscover_EmptyLogs(t *testing.T) {
 }
 
 func TestDiscover_NilAttributes(t *testing.T) {
-	logs := []datadogV2.Log{
+	logs := []ingestor.LogRecord{
 		{Attributes: nil},
-		{Attributes: &datadogV2.LogAttributes{Status: strPtr("ok")}},
+		{Status: "ok"},
 	}
 	s := Discover(logs)
 	if !s.HasField("status") {
@@ -89,10 +84,10 @@ func TestDiscover_NilAttributes(t *testing.T) {
 }
 
 func TestDiscover_Cardinality(t *testing.T) {
-	logs := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{Status: strPtr("error")}},
-		{Attributes: &datadogV2.LogAttributes{Status: strPtr("warning")}},
-		{Attributes: &datadogV2.LogAttributes{Status: strPtr("info")}},
+	logs := []ingestor.LogRecord{
+		{Status: "error"},
+		{Status: "warning"},
+		{Status: "info"},
 	}
 	s := Discover(logs)
 	for _, f := range s.Fields {
@@ -107,14 +102,14 @@ func TestDiscover_Cardinality(t *testing.T) {
 }
 
 func TestDiscover_FieldTypes(t *testing.T) {
-	logs := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{
+	logs := []ingestor.LogRecord{
+		{
 			Attributes: map[string]interface{}{
 				"count":   float64(42),
 				"enabled": true,
 				"name":    "test",
 			},
-		}},
+		},
 	}
 	s := Discover(logs)
 
@@ -140,18 +135,29 @@ func TestDiscover_MaxSampleSize(t *testing.T) {
 	logs := makeLogs(500)
 	s := Discover(logs)
 	if len(s.Fields) == 0 {
-		t.Error("should discover fields even with > maxSampleSize logs")
+		t.Error("should discover fields even with > DefaultSampleSize logs")
+	}
+}
+
+func TestDiscoverWithSampleSize(t *testing.T) {
+	logs := []ingestor.LogRecord{
+		{Status: "error"},
+		{Status: "error", Host: "web-01"},
+	}
+	if s := DiscoverWithSampleSize(logs, 1); s.HasField("host") {
+		t.Error("fields beyond the sample should not be discovered")
+	}
+	if s := DiscoverWithSampleSize(logs, 2); !s.HasField("host") {
+		t.Error("host should be discovered when it is within the sample")
 	}
 }
 
 func TestDiscover_ExamplesLimited(t *testing.T) {
-	logs := make([]datadogV2.Log, 20)
+	logs := make([]ingestor.LogRecord, 20)
 	for i := range logs {
 		status := "status_" + string(rune('a'+i))
-		logs[i] = datadogV2.Log{
-			Attributes: &datadogV2.LogAttributes{
-				Status: strPtr(status),
-			},
+		logs[i] = ingestor.LogRecord{
+			Status: status,
 		}
 	}
 	s := Discover(logs)
@@ -185,17 +191,35 @@ func TestSchema_HasField(t *testing.T) {
 	}
 }
 
+func TestSchema_Select(t *testing.T) {
+	s := Schema{Fields: []Field{
+		{Name: "status", Type: FieldTypeString, Cardinality: 3},
+		{Name: "host", Type: FieldTypeString},
+		{Name: "env", Type: FieldTypeString},
+	}}
+	selected := s.Select([]string{"env", "status", "region"})
+	if names := selected.FieldNames(); len(names) != 3 || names[0] != "env" || names[1] != "status" || names[2] != "region" {
+		t.Fatalf("Select: got %v", names)
+	}
+	if selected.Fields[1].Cardinality != 3 {
+		t.Error("Select should keep discovered field details")
+	}
+	if selected.Fields[2].Type != FieldTypeUnknown {
+		t.Errorf("undiscovered field type: got %q, want unknown", selected.Fields[2].Type)
+	}
+}
+
 func TestCache_RefreshesEveryN(t *testing.T) {
 	c := NewCache(3)
 
-	logs1 := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{Status: strPtr("error")}},
+	logs1 := []ingestor.LogRecord{
+		{Status: "error"},
 	}
-	logs2 := []datadogV2.Log{
-		{Attributes: &datadogV2.LogAttributes{
-			Status: strPtr("error"),
-			Host:   strPtr("web-01"),
-		}},
+	logs2 := []ingestor.LogRecord{
+		{
+			Status: "error",
+			Host:   "web-01",
+		},
 	}
 
 	s1 := c.Get(logs1)
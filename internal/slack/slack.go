@@ -15,9 +15,36 @@ type Config struct {
 	ChannelID string
 }
 
+type Payload struct {
+	Channel string        `json:"channel"`
+	Blocks  []slack.Block `json:"blocks"`
+}
+
 func SendMessage(result analyzer.AnalysisResult, config Config) error {
 	api := slack.New(config.BotToken)
 
+	_, msgTimestamp, err := api.PostMessage(
+		config.ChannelID,
+		slack.MsgOptionBlocks(Blocks(result)...),
+	)
+	if err != nil {
+		log.Err(err).Str("channel", config.ChannelID).Msg("Failed to post Slack message")
+		return err
+	}
+
+	log.Info().
+		Str("channel", config.ChannelID).
+		Str("timestamp", msgTimestamp).
+		Msg("Summary posted to Slack")
+	return nil
+}
+
+// Render returns the Block Kit message SendMessage would post, for dry runs.
+func Render(result analyzer.AnalysisResult, config Config) Payload {
+	return Payload{Channel: config.ChannelID, Blocks: Blocks(result)}
+}
+
+func Blocks(result analyzer.AnalysisResult) []slack.Block {
 	severityEmoji := severityToEmoji(result.Severity)
 
 	blocks := []slack.Block{
@@ -29,7 +56,7 @@ func SendMessage(result analyzer.AnalysisResult, config Config) error {
 		slack.NewDividerBlock(),
 		slack.NewSectionBlock(
 			slack.NewTextBlockObject("mrkdwn",
-				fmt.Sprintf("*Signal Strength:* %d/10\n*Severity:* %s",
+				monitorLine(result.Monitor)+fmt.Sprintf("*Signal Strength:* %d/10\n*Severity:* %s",
 					result.SignalStrength, result.Severity),
 				false, false),
 			nil, nil,
@@ -64,20 +91,7 @@ func SendMessage(result analyzer.AnalysisResult, config Config) error {
 		))
 	}
 
-	_, msgTimestamp, err := api.PostMessage(
-		config.ChannelID,
-		slack.MsgOptionBlocks(blocks...),
-	)
-	if err != nil {
-		log.Err(err).Str("channel", config.ChannelID).Msg("Failed to post Slack message")
-		return err
-	}
-
-	log.Info().
-		Str("channel", config.ChannelID).
-		Str("timestamp", msgTimestamp).
-		Msg("Summary posted to Slack")
-	return nil
+	return blocks
 }
 
 func severityToEmoji(severity string) string {
@@ -92,3 +106,10 @@ func severityToEmoji(severity string) string {
 		return "🟢"
 	}
 }
+
+func monitorLine(monitor string) string {
+	if monitor == "" {
+		return ""
+	}
+	return fmt.Sprintf("*Monitor:* %s\n", monitor)
+}
@@ -0,0 +1,49 @@
+package slack
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+)
+
+func TestRender(t *testing.T) {
+	result := analyzer.AnalysisResult{
+		SignalStrength: 8,
+		SendSummary:    true,
+		Severity:       "high",
+		Reasoning:      "Error rate tripled on api",
+		KeyPoints:      []string{"status=error up 300%"},
+		Timestamp:      "2024-01-01T12:00:00Z",
+		Monitor:        "api",
+	}
+
+	payload := Render(result, Config{BotToken: "xoxb", ChannelID: "C1"})
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded struct {
+		Channel string           `json:"channel"`
+		Blocks  []map[string]any `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Channel != "C1" {
+		t.Errorf("channel: got %q", decoded.Channel)
+	}
+	if len(decoded.Blocks) != 6 || decoded.Blocks[0]["type"] != "header" || decoded.Blocks[5]["type"] != "context" {
+		t.Errorf("blocks: got %s", data)
+	}
+	for _, want := range []string{"*Monitor:* api", "Error rate tripled on api", "status=error up 300%"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("payload should contain %q", want)
+		}
+	}
+	if strings.Contains(string(data), "xoxb") {
+		t.Error("payload must not include the bot token")
+	}
+}
@@ -0,0 +1,206 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const fileExt = ".jsonl"
+
+// FileStore keeps each monitor's history as a JSON line per cycle in its own
+// file under dir, and serves queries from memory. Cycles retention drops stay
+// in the file until they make up half of it, or until it is next loaded, so
+// a long history isn't rewritten on every append. Without a dir nothing is
+// persisted.
+type FileStore struct {
+	dir       string
+	retention Retention
+	now       func() time.Time
+
+	mu     sync.Mutex
+	cycles map[string][]Cycle
+	// lines counts the cycles in each monitor's file, dropped ones included.
+	lines map[string]int
+}
+
+func NewFileStore(dir string, retention Retention) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{
+		dir:       dir,
+		retention: retention,
+		now:       time.Now,
+		cycles:    make(map[string][]Cycle),
+		lines:     make(map[string]int),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func NewMemoryStore(retention Retention) *FileStore {
+	return &FileStore{
+		retention: retention,
+		now:       time.Now,
+		cycles:    make(map[string][]Cycle),
+	}
+}
+
+func (s *FileStore) Append(c Cycle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cycles := s.cycles[c.Monitor]
+	i := sort.Search(len(cycles), func(i int) bool { return cycles[i].Window.To.After(c.Window.To) })
+	cycles = append(cycles, Cycle{})
+	copy(cycles[i+1:], cycles[i:])
+	cycles[i] = c
+
+	kept := s.retention.apply(cycles, s.now())
+	s.cycles[c.Monitor] = kept
+	if s.dir == "" {
+		return nil
+	}
+	dropped := s.lines[c.Monitor] + 1 - len(kept)
+	if i < len(cycles)-1 || dropped > len(kept) {
+		return s.rewrite(c.Monitor)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path(c.Monitor), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	s.lines[c.Monitor]++
+	return f.Close()
+}
+
+func (s *FileStore) Query(q Query) ([]Cycle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Cycle
+	for monitor, cycles := range s.cycles {
+		if q.Monitor != "" && monitor != q.Monitor {
+			continue
+		}
+		for _, c := range cycles {
+			if q.matches(c) {
+				out = append(out, c)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Window.To.Before(out[j].Window.To) })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out, nil
+}
+
+func (s *FileStore) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), fileExt) {
+			continue
+		}
+		cycles, err := readCycles(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if len(cycles) == 0 {
+			continue
+		}
+		monitor := cycles[0].Monitor
+		sort.SliceStable(cycles, func(i, j int) bool { return cycles[i].Window.To.Before(cycles[j].Window.To) })
+
+		kept := s.retention.apply(cycles, s.now())
+		s.cycles[monitor] = kept
+		s.lines[monitor] = len(cycles)
+		if len(kept) < len(cycles) {
+			if err := s.rewrite(monitor); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readCycles skips lines it cannot decode, such as one cut short by a crash
+// mid-write, rather than losing the whole history.
+func readCycles(path string) ([]Cycle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cycles []Cycle
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var c Cycle
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			log.Warn().Err(err).Str("path", path).Int("line", line).Msg("Skipping unreadable state entry")
+			continue
+		}
+		cycles = append(cycles, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cycles, nil
+}
+
+func (s *FileStore) rewrite(monitor string) error {
+	path := s.path(monitor)
+	tmp, err := os.CreateTemp(s.dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, c := range s.cycles[monitor] {
+		if err := enc.Encode(c); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := errors.Join(w.Flush(), tmp.Close()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	s.lines[monitor] = len(s.cycles[monitor])
+	return nil
+}
+
+func (s *FileStore) path(monitor string) string {
+	return filepath.Join(s.dir, url.PathEscape(monitor)+fileExt)
+}
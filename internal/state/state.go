@@ -0,0 +1,185 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+)
+
+const (
+	BackendFile   = "file"
+	BackendMemory = "memory"
+	BackendNone   = "none"
+)
+
+var Backends = []string{BackendFile, BackendMemory, BackendNone}
+
+// Cycle is what the store keeps about one aggregation cycle: enough to
+// explain an alert after the fact and to compare against later cycles.
+type Cycle struct {
+	Monitor       string                    `json:"monitor"`
+	Source        string                    `json:"source"`
+	Query         string                    `json:"query"`
+	Dimensions    []string                  `json:"dimensions,omitempty"`
+	Window        ingestor.AbsoluteRange    `json:"window"`
+	RecordedAt    time.Time                 `json:"recordedAt"`
+	Truncated     bool                      `json:"truncated,omitempty"`
+	Schema        schema.Schema             `json:"schema"`
+	Counts        map[string]map[string]int `json:"counts"`
+	Templates     []Template                `json:"templates,omitempty"`
+	Analysis      *analyzer.AnalysisResult  `json:"analysis,omitempty"`
+	AnalysisError string                    `json:"analysisError,omitempty"`
+	Notifications []Notification            `json:"notifications,omitempty"`
+}
+
+type Template struct {
+	Template string `json:"template"`
+	Count    int    `json:"count"`
+}
+
+// Notification is the outcome of delivering a cycle's analysis to one
+// destination.
+type Notification struct {
+	Destination string `json:"destination"`
+	Channel     string `json:"channel"`
+	Sent        bool   `json:"sent"`
+	DryRun      bool   `json:"dryRun,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Alerted reports whether the cycle's analysis crossed the alert threshold.
+func (c Cycle) Alerted() bool {
+	return c.Analysis != nil && c.Analysis.SendSummary
+}
+
+// NewCycle summarizes result, leaving the monitor settings for the caller.
+// Message templates are collected across all dimensions, since every
+// dimension groups the same messages.
+func NewCycle(result aggregator.AggregationResult) Cycle {
+	c := Cycle{
+		Monitor:    result.Monitor,
+		Window:     result.Window,
+		RecordedAt: time.Now().UTC(),
+		Truncated:  result.Truncated,
+		Schema:     result.Schema,
+		Counts:     make(map[string]map[string]int, len(result.CurrentLogs.Dimensions)),
+	}
+
+	templates := make(map[string]int)
+	for name, dim := range result.CurrentLogs.Dimensions {
+		c.Counts[name] = dim.Counts
+		for _, g := range dim.MessageGroups {
+			templates[g.Template] = max(templates[g.Template], g.Count)
+		}
+	}
+	for t, count := range templates {
+		c.Templates = append(c.Templates, Template{Template: t, Count: count})
+	}
+	sort.Slice(c.Templates, func(i, j int) bool {
+		if c.Templates[i].Count != c.Templates[j].Count {
+			return c.Templates[i].Count > c.Templates[j].Count
+		}
+		return c.Templates[i].Template < c.Templates[j].Template
+	})
+	return c
+}
+
+// Query selects stored cycles. Since and Until bound the window end, and
+// Limit keeps only the most recent matches.
+type Query struct {
+	Monitor    string
+	Since      time.Time
+	Until      time.Time
+	AlertsOnly bool
+	Limit      int
+}
+
+func (q Query) matches(c Cycle) bool {
+	if q.Monitor != "" && c.Monitor != q.Monitor {
+		return false
+	}
+	if !q.Since.IsZero() && c.Window.To.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !c.Window.To.Before(q.Until) {
+		return false
+	}
+	return !q.AlertsOnly || c.Alerted()
+}
+
+// Retention bounds how much history is kept per monitor. Zero values keep
+// everything.
+type Retention struct {
+	MaxAge    time.Duration
+	MaxCycles int
+}
+
+// Store keeps cycle history. Query returns matches ordered by window end,
+// oldest first.
+type Store interface {
+	Append(c Cycle) error
+	Query(q Query) ([]Cycle, error)
+}
+
+// Open returns the store for backend, or nil for BackendNone.
+func Open(backend, path string, retention Retention) (Store, error) {
+	switch backend {
+	case BackendFile:
+		if path == "" {
+			path = DefaultPath()
+		}
+		s, err := NewFileStore(path, retention)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendMemory:
+		return NewMemoryStore(retention), nil
+	case BackendNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown state backend %q, must be one of %v", backend, Backends)
+}
+
+// DefaultPath follows the XDG base directory spec for state data.
+func DefaultPath() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "lumberjack")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "lumberjack")
+	}
+	return "lumberjack-state"
+}
+
+// Latest returns the most recent cycle stored for monitor.
+func Latest(s Store, monitor string) (Cycle, bool, error) {
+	cycles, err := s.Query(Query{Monitor: monitor, Limit: 1})
+	if err != nil || len(cycles) == 0 {
+		return Cycle{}, false, err
+	}
+	return cycles[0], true, nil
+}
+
+func (r Retention) apply(cycles []Cycle, now time.Time) []Cycle {
+	if r.MaxAge > 0 {
+		cutoff := now.Add(-r.MaxAge)
+		i := slices.IndexFunc(cycles, func(c Cycle) bool { return !c.Window.To.Before(cutoff) })
+		if i < 0 {
+			i = len(cycles)
+		}
+		cycles = cycles[i:]
+	}
+	if r.MaxCycles > 0 && len(cycles) > r.MaxCycles {
+		cycles = cycles[len(cycles)-r.MaxCycles:]
+	}
+	return cycles
+}
@@ -0,0 +1,233 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/fuzzy"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func cycleAt(monitor string, minutes int, alerted bool) Cycle {
+	return cycleFrom(base, monitor, minutes, alerted)
+}
+
+func cycleFrom(start time.Time, monitor string, minutes int, alerted bool) Cycle {
+	end := start.Add(time.Duration(minutes) * time.Minute)
+	return Cycle{
+		Monitor:  monitor,
+		Window:   ingestor.NewAbsoluteRange(end.Add(-15*time.Minute), end),
+		Analysis: &analyzer.AnalysisResult{SendSummary: alerted},
+	}
+}
+
+func windowEnds(cycles []Cycle) []int {
+	return windowEndsFrom(base, cycles)
+}
+
+func windowEndsFrom(start time.Time, cycles []Cycle) []int {
+	out := make([]int, len(cycles))
+	for i, c := range cycles {
+		out[i] = int(c.Window.To.Sub(start) / time.Minute)
+	}
+	return out
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFileStore_PersistsAndQueries(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Cycle{
+		cycleAt("api", 15, false),
+		cycleAt("api", 45, true),
+		cycleAt("api", 30, false),
+		cycleAt("web/edge", 30, true),
+	} {
+		if err := s.Append(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := NewFileStore(dir, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []int
+	}{
+		{name: "monitor", q: Query{Monitor: "api"}, want: []int{15, 30, 45}},
+		{name: "all monitors", q: Query{}, want: []int{15, 30, 30, 45}},
+		{name: "alerts", q: Query{Monitor: "api", AlertsOnly: true}, want: []int{45}},
+		{name: "range", q: Query{Monitor: "api", Since: base.Add(30 * time.Minute), Until: base.Add(45 * time.Minute)}, want: []int{30}},
+		{name: "limit", q: Query{Monitor: "api", Limit: 2}, want: []int{30, 45}},
+		{name: "escaped name", q: Query{Monitor: "web/edge"}, want: []int{30}},
+	}
+	for _, tt := range tests {
+		got, err := reopened.Query(tt.q)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !equalInts(windowEnds(got), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, windowEnds(got), tt.want)
+		}
+	}
+
+	latest, ok, err := Latest(reopened, "api")
+	if err != nil || !ok || !latest.Alerted() {
+		t.Errorf("Latest: got %+v, %v, %v", latest, ok, err)
+	}
+}
+
+func TestFileStore_Retention(t *testing.T) {
+	// Reopening prunes against the real clock, so windows end relative to it.
+	start := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Minute)
+	dir := t.TempDir()
+	s, err := NewFileStore(dir, Retention{MaxAge: 3 * time.Hour, MaxCycles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for m := 15; m <= 120; m += 15 {
+		if err := s.Append(cycleFrom(start, "api", m, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := s.Query(Query{Monitor: "api"})
+	if want := []int{90, 105, 120}; !equalInts(windowEndsFrom(start, got), want) {
+		t.Errorf("after MaxCycles: got %v, want %v", windowEndsFrom(start, got), want)
+	}
+
+	reopened, err := NewFileStore(dir, Retention{MaxAge: 20 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = reopened.Query(Query{Monitor: "api"})
+	if want := []int{105, 120}; !equalInts(windowEndsFrom(start, got), want) {
+		t.Errorf("after MaxAge: got %v, want %v", windowEndsFrom(start, got), want)
+	}
+}
+
+func TestFileStore_CompactsOnceDroppedCyclesAreHalf(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir, Retention{MaxCycles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := func() int {
+		data, err := os.ReadFile(s.path("api"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(data), "\n")
+	}
+	var sizes []int
+	for m := 15; m <= 150; m += 15 {
+		if err := s.Append(cycleAt("api", m, false)); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, lines())
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 3, 4, 5, 6}; !equalInts(sizes, want) {
+		t.Errorf("lines after each append: got %v, want %v", sizes, want)
+	}
+
+	reopened, err := NewFileStore(dir, Retention{MaxCycles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := reopened.Query(Query{Monitor: "api"})
+	if want := []int{120, 135, 150}; !equalInts(windowEnds(got), want) {
+		t.Errorf("reopened: got %v, want %v", windowEnds(got), want)
+	}
+	if n := lines(); n != 3 {
+		t.Errorf("lines after reopening: got %d, want 3", n)
+	}
+}
+
+func TestFileStore_SkipsCorruptLines(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(cycleAt("api", 15, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "api.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"monitor":"api","win`)
+	f.Close()
+
+	reopened, err := NewFileStore(dir, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := reopened.Query(Query{Monitor: "api"})
+	if len(got) != 1 {
+		t.Errorf("got %d cycles, want 1", len(got))
+	}
+}
+
+func TestNewCycle(t *testing.T) {
+	result := aggregator.AggregationResult{
+		Monitor: "api",
+		CurrentLogs: aggregator.Aggregates{Dimensions: map[string]*aggregator.DimensionData{
+			"service": {
+				Counts:        map[string]int{"api": 3, "web": 1},
+				MessageGroups: []fuzzy.MessageGroup{{Template: "timeout after <N>ms", Count: 3}, {Template: "retrying", Count: 1}},
+			},
+			"host": {
+				Counts:        map[string]int{"web-01": 4},
+				MessageGroups: []fuzzy.MessageGroup{{Template: "timeout after <N>ms", Count: 3}, {Template: "retrying", Count: 1}},
+			},
+		}},
+	}
+
+	c := NewCycle(result)
+	if c.Monitor != "api" || c.Counts["service"]["api"] != 3 || c.Counts["host"]["web-01"] != 4 {
+		t.Errorf("counts: got %+v", c.Counts)
+	}
+	if len(c.Templates) != 2 || c.Templates[0] != (Template{Template: "timeout after <N>ms", Count: 3}) {
+		t.Errorf("templates: got %+v", c.Templates)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if s, err := Open(BackendNone, "", Retention{}); s != nil || err != nil {
+		t.Errorf("none: got %v, %v", s, err)
+	}
+	if _, err := Open("redis", "", Retention{}); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+	if s, err := Open(BackendFile, t.TempDir(), Retention{}); s == nil || err != nil {
+		t.Errorf("file: got %v, %v", s, err)
+	}
+}
@@ -0,0 +1,107 @@
+package supervisor
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+	slackpkg "github.com/ricardonunez-io/lumberjack/internal/slack"
+)
+
+type Delivery struct {
+	Monitor  config.Monitor
+	Analyzer analyzer.Config
+	Slack    []slackpkg.Config
+}
+
+func DeliveryFor(m config.Monitor, cfg config.Config) Delivery {
+	d := Delivery{
+		Monitor:  m,
+		Analyzer: analyzer.Config{APIKey: cfg.Anthropic.APIKey, Model: cfg.Anthropic.Model},
+	}
+	for _, dest := range m.Destinations {
+		d.Slack = append(d.Slack, slackpkg.Config{BotToken: cfg.Slack.BotToken, ChannelID: dest.Channel})
+	}
+	return d
+}
+
+// MonitorConfig converts an already validated monitor into an aggregation
+// config, so parse errors here only guard against callers skipping Validate.
+// The returned config has no Source.
+func MonitorConfig(m config.Monitor, cfg config.Config) (aggregator.AggregationConfig, error) {
+	timeInterval, err := ingestor.ParseInterval(m.TimeInterval)
+	if err != nil {
+		return aggregator.AggregationConfig{}, err
+	}
+	historicalTimeInterval, err := ingestor.ParseInterval(m.HistoricalTimeInterval)
+	if err != nil {
+		return aggregator.AggregationConfig{}, err
+	}
+	ingestionDelay, err := time.ParseDuration(cfg.IngestionDelay)
+	if err != nil {
+		return aggregator.AggregationConfig{}, err
+	}
+
+	var schedule aggregator.Schedule
+	if m.Schedule != "" {
+		schedule, err = aggregator.ParseSchedule(m.Schedule, m.ScheduleTimezone)
+		if err != nil {
+			return aggregator.AggregationConfig{}, err
+		}
+	}
+
+	var cycleTimeout time.Duration
+	if m.CycleTimeout != "" {
+		cycleTimeout, err = time.ParseDuration(m.CycleTimeout)
+		if err != nil {
+			return aggregator.AggregationConfig{}, err
+		}
+	}
+
+	aggCfg := aggregator.AggregationConfig{
+		Name:                   m.Name,
+		Dimensions:             m.Dimensions,
+		TimeInterval:           timeInterval,
+		Query:                  m.Query,
+		LogSeverity:            m.Severity,
+		HistoricalTimeInterval: historicalTimeInterval,
+		IngestionDelay:         ingestionDelay,
+		Schedule:               schedule,
+		SchemaCache:            schema.NewCacheWithSampleSize(cfg.Schema.RefreshEvery, cfg.Schema.SampleSize),
+		ServerSideHistorical:   cfg.Historical.ServerSideCounts,
+		HistoricalSampleSize:   cfg.Historical.SampleSize,
+		SimilarityThreshold:    cfg.Fuzzy.SimilarityThreshold,
+		Overlap:                m.Overlap,
+		CycleTimeout:           cycleTimeout,
+	}
+	if cfg.Historical.Baseline {
+		aggCfg.Baseline = aggregator.NewBaseline(timeInterval, historicalTimeInterval, BaselinePath(cfg.Historical.BaselinePath, m.Name))
+	}
+	return aggCfg, nil
+}
+
+func BaselinePath(path, monitor string) string {
+	if path == "" || monitor == "default" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + monitor + ext
+}
+
+// Retention is how long push receivers must buffer logs to serve the
+// longest historical window of any monitor in cfg.
+func Retention(cfg config.Config) time.Duration {
+	delay, _ := time.ParseDuration(cfg.IngestionDelay)
+	retention := time.Duration(0)
+	for _, m := range cfg.ResolvedMonitors() {
+		interval, _ := ingestor.ParseInterval(m.TimeInterval)
+		historical, _ := ingestor.ParseInterval(m.HistoricalTimeInterval)
+		retention = max(retention, historical+2*interval+delay)
+	}
+	return retention
+}
@@ -0,0 +1,136 @@
+package supervisor
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+)
+
+var ErrUnknownMonitor = errors.New("unknown monitor")
+
+// MonitorStatus is a snapshot of one monitor's loop.
+type MonitorStatus struct {
+	Name                string                   `json:"name"`
+	Source              string                   `json:"source"`
+	Query               string                   `json:"query"`
+	TimeInterval        time.Duration            `json:"timeInterval"`
+	StartedAt           time.Time                `json:"startedAt"`
+	NextRun             time.Time                `json:"nextRun,omitzero"`
+	LastRun             time.Time                `json:"lastRun,omitzero"`
+	LastSuccess         time.Time                `json:"lastSuccess,omitzero"`
+	LastWindow          *ingestor.AbsoluteRange  `json:"lastWindow,omitempty"`
+	LastLogCount        int                      `json:"lastLogCount"`
+	LastTruncated       bool                     `json:"lastTruncated,omitempty"`
+	LastError           string                   `json:"lastError,omitempty"`
+	ConsecutiveFailures int                      `json:"consecutiveFailures"`
+	MissedCycles        int                      `json:"missedCycles"`
+	LastAnalysis        *analyzer.AnalysisResult `json:"lastAnalysis,omitempty"`
+}
+
+// Ready reports whether the loop is keeping up: it is not more than n
+// intervals past a scheduled run, and fewer than n cycles in a row failed.
+// A loop that has not finished a cycle yet gets n intervals to do so.
+func (st MonitorStatus) Ready(now time.Time, n int) bool {
+	grace := time.Duration(n) * st.TimeInterval
+	if st.ConsecutiveFailures >= n {
+		return false
+	}
+	if !st.NextRun.IsZero() && now.Sub(st.NextRun) > grace {
+		return false
+	}
+	return !st.LastSuccess.IsZero() || now.Sub(st.StartedAt) <= grace
+}
+
+// status records a loop's progress; it is the loop's aggregator.Observer.
+type status struct {
+	mu sync.Mutex
+	MonitorStatus
+}
+
+func (st *status) CycleScheduled(at time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.NextRun = at
+}
+
+func (st *status) CycleFailed(end time.Time, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.LastRun = time.Now()
+	st.LastError = err.Error()
+	st.ConsecutiveFailures++
+}
+
+func (st *status) CycleMissed(end time.Time, reason string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.MissedCycles++
+}
+
+func (st *status) cycleCompleted(window ingestor.AbsoluteRange, logCount int, truncated bool, analysis *analyzer.AnalysisResult, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.LastRun = time.Now()
+	st.LastWindow = &window
+	st.LastLogCount = logCount
+	st.LastTruncated = truncated
+	if analysis != nil {
+		st.LastAnalysis = analysis
+	}
+	if err != nil {
+		st.LastError = err.Error()
+		st.ConsecutiveFailures++
+		return
+	}
+	st.LastSuccess = st.LastRun
+	st.ConsecutiveFailures = 0
+	st.LastError = ""
+}
+
+func (st *status) snapshot() MonitorStatus {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.MonitorStatus
+}
+
+// Status returns a snapshot of every running monitor, ordered by name.
+func (s *Supervisor) Status() []MonitorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]MonitorStatus, 0, len(s.monitors))
+	for _, m := range s.monitors {
+		statuses = append(statuses, m.status.snapshot())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Trigger asks the named monitor, or every monitor when name is empty, to
+// run a cycle now, and returns the monitors that were asked. A monitor that
+// already has a run pending is not asked twice.
+func (s *Supervisor) Trigger(name string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for n, m := range s.monitors {
+		if name != "" && n != name {
+			continue
+		}
+		select {
+		case m.trigger <- struct{}{}:
+		default:
+		}
+		names = append(names, n)
+	}
+	if len(names) == 0 && name != "" {
+		return nil, ErrUnknownMonitor
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,455 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
+	"github.com/rs/zerolog/log"
+)
+
+type SourceFactory func(ctx context.Context, sources config.SourcesConfig, kind string, retention time.Duration) (ingestor.LogSource, error)
+
+type ResultHandler func(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error)
+
+// Push receivers own listeners and the only copy of their buffered logs, so
+// they are kept across reloads and only replaced by a restart.
+var pushSources = map[string]bool{"otlp": true, "syslog": true}
+
+// Supervisor runs one aggregation loop per monitor and applies new
+// configurations by restarting only the loops whose inputs changed.
+type Supervisor struct {
+	buildSource SourceFactory
+	handle      ResultHandler
+
+	// WrapSource, when set, wraps the source each loop reads from, e.g. to
+	// record fixtures. It must be set before Start.
+	WrapSource func(aggregator.AggregationConfig, ingestor.LogSource) ingestor.LogSource
+
+	// State, when set, seeds each new monitor's schema cache from its last
+	// stored cycle so a restart doesn't rediscover it. It must be set before
+	// Start.
+	State state.Store
+
+	mu       sync.Mutex
+	ctx      context.Context
+	stopping bool
+	cfg      config.Config
+	sources  map[string]*source
+	monitors map[string]*monitor
+	wg       sync.WaitGroup
+
+	// Deliveries outlive the loops' context so a shutdown can let them
+	// finish; cancelDeliveries ends them, and what they didn't deliver is
+	// kept in undelivered.
+	deliveries       context.Context
+	cancelDeliveries context.CancelFunc
+	undeliveredMu    sync.Mutex
+	undelivered      []aggregator.AggregationResult
+}
+
+type source struct {
+	settings any
+	source   ingestor.LogSource
+	cancel   context.CancelFunc
+}
+
+type monitor struct {
+	spec     config.Monitor
+	aggCfg   aggregator.AggregationConfig
+	delivery atomic.Pointer[Delivery]
+	status   *status
+	trigger  chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+type loopSettings struct {
+	IngestionDelay string
+	Historical     config.HistoricalConfig
+	Schema         config.SchemaConfig
+	Fuzzy          config.FuzzyConfig
+}
+
+func New(buildSource SourceFactory, handle ResultHandler) *Supervisor {
+	return &Supervisor{
+		buildSource: buildSource,
+		handle:      handle,
+		sources:     make(map[string]*source),
+		monitors:    make(map[string]*monitor),
+	}
+}
+
+// Start builds the sources and starts a loop for every monitor in cfg.
+// Loops stop scheduling cycles when ctx is cancelled; results they already
+// produced are still delivered until Shutdown gives up on them.
+func (s *Supervisor) Start(ctx context.Context, cfg config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+	s.deliveries, s.cancelDeliveries = context.WithCancel(context.WithoutCancel(ctx))
+	return s.apply(cfg)
+}
+
+// Reload applies cfg to the running monitors. Monitors whose only changes
+// are destinations or analyzer settings keep running; changed monitors are
+// stopped and restarted, and immediately run the latest window so no cycle
+// is lost. If any new source or monitor cannot be built, nothing changes.
+func (s *Supervisor) Reload(cfg config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return fmt.Errorf("supervisor not started")
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.apply(cfg)
+}
+
+// Wait blocks until every loop has stopped.
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
+
+// Shutdown waits for the loops, whose context must already be cancelled,
+// to deliver the results they produced. When ctx is done first, deliveries
+// still running are cancelled. It returns every result that was not
+// delivered.
+func (s *Supervisor) Shutdown(ctx context.Context) []aggregator.AggregationResult {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn().Msg("Shutdown grace period is over, cancelling deliveries in flight")
+		if s.cancelDeliveries != nil {
+			s.cancelDeliveries()
+		}
+		<-done
+	}
+
+	s.undeliveredMu.Lock()
+	defer s.undeliveredMu.Unlock()
+	return s.undelivered
+}
+
+// Redeliver hands a result from an earlier run to its monitor's handler and
+// reports whether it was handled, successfully or not. A result that
+// arrives during shutdown, or is cut off by it, is not handled and is
+// returned by Shutdown instead.
+func (s *Supervisor) Redeliver(result aggregator.AggregationResult) (bool, error) {
+	s.mu.Lock()
+	if s.stopping || s.ctx == nil || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return false, nil
+	}
+	m, ok := s.monitors[result.Monitor]
+	if !ok {
+		s.mu.Unlock()
+		return false, ErrUnknownMonitor
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	defer s.wg.Done()
+	return s.deliver(m, result), nil
+}
+
+// deliver runs the handler for result under the cycle deadline and records
+// the outcome. It returns false, keeping result for Shutdown, when
+// deliveries were cancelled before or during the call.
+func (s *Supervisor) deliver(m *monitor, result aggregator.AggregationResult) bool {
+	if s.deliveries.Err() != nil {
+		s.keepUndelivered(result)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(s.deliveries, aggregator.CycleTimeout(m.aggCfg))
+	analysis, err := s.handle(ctx, result, *m.delivery.Load())
+	cancel()
+	if err != nil && s.deliveries.Err() != nil {
+		s.keepUndelivered(result)
+		return false
+	}
+	if err != nil {
+		log.Err(err).Str("monitor", result.Monitor).Msg("Error processing aggregation result")
+	}
+	m.status.cycleCompleted(result.Window, result.LogCount, result.Truncated, analysis, err)
+	return true
+}
+
+func (s *Supervisor) keepUndelivered(result aggregator.AggregationResult) {
+	s.undeliveredMu.Lock()
+	defer s.undeliveredMu.Unlock()
+	s.undelivered = append(s.undelivered, result)
+}
+
+func (s *Supervisor) apply(cfg config.Config) error {
+	monitors := cfg.ResolvedMonitors()
+
+	sources, rebuilt, err := s.resolveSources(cfg, monitors)
+	discard := func() {
+		for kind, src := range sources {
+			if rebuilt[kind] {
+				src.cancel()
+			}
+		}
+	}
+	if err != nil {
+		discard()
+		return err
+	}
+
+	globalChanged := settingsOf(s.cfg) != settingsOf(cfg)
+	next := make(map[string]*monitor, len(monitors))
+	var started, restarted, updated []*monitor
+	for _, spec := range monitors {
+		old := s.monitors[spec.Name]
+		delivery := DeliveryFor(spec, cfg)
+
+		if old != nil && !globalChanged && !rebuilt[spec.Source] && sameLoop(old.spec, spec) {
+			old.spec = spec
+			old.delivery.Store(&delivery)
+			next[spec.Name] = old
+			updated = append(updated, old)
+			continue
+		}
+
+		aggCfg, err := MonitorConfig(spec, cfg)
+		if err != nil {
+			discard()
+			return fmt.Errorf("monitor %q: %w", spec.Name, err)
+		}
+		aggCfg.Source = sources[spec.Source].source
+		if s.WrapSource != nil {
+			aggCfg.Source = s.WrapSource(aggCfg, aggCfg.Source)
+		}
+
+		m := &monitor{spec: spec, aggCfg: aggCfg, status: &status{}, trigger: make(chan struct{}, 1)}
+		m.delivery.Store(&delivery)
+		next[spec.Name] = m
+		if old != nil {
+			carryOver(old, s.cfg, m, cfg, rebuilt[spec.Source])
+			restarted = append(restarted, m)
+		} else {
+			s.seedSchema(m)
+			started = append(started, m)
+		}
+	}
+
+	stopped := 0
+	for name, old := range s.monitors {
+		if next[name] != old {
+			old.cancel()
+			<-old.done
+			if _, ok := next[name]; !ok {
+				log.Info().Str("monitor", name).Msg("Monitor removed")
+				stopped++
+			}
+		}
+	}
+	for kind, src := range s.sources {
+		if sources[kind] != src {
+			src.cancel()
+		}
+	}
+	// Taken once the old loop has stopped, so it includes the window the
+	// old loop delivered last.
+	for _, m := range restarted {
+		m.status.MonitorStatus = s.monitors[m.spec.Name].status.snapshot()
+	}
+
+	s.cfg, s.sources, s.monitors = cfg, sources, next
+	for _, m := range append(started, restarted...) {
+		s.run(m)
+	}
+
+	log.Info().
+		Int("started", len(started)).
+		Int("restarted", len(restarted)).
+		Int("updated", len(updated)).
+		Int("stopped", stopped).
+		Msg("Configuration applied")
+	return nil
+}
+
+func (s *Supervisor) resolveSources(cfg config.Config, monitors []config.Monitor) (map[string]*source, map[string]bool, error) {
+	retention := Retention(cfg)
+	sources := make(map[string]*source)
+	rebuilt := make(map[string]bool)
+
+	for _, m := range monitors {
+		if _, ok := sources[m.Source]; ok {
+			continue
+		}
+
+		settings := sourceSettings(cfg.Sources, m.Source)
+		if old, ok := s.sources[m.Source]; ok {
+			if reflect.DeepEqual(old.settings, settings) {
+				sources[m.Source] = old
+				continue
+			}
+			if pushSources[m.Source] {
+				log.Warn().Str("source", m.Source).Msg("Receiver settings changed, restart to apply them")
+				sources[m.Source] = old
+				continue
+			}
+		}
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		src, err := s.buildSource(ctx, cfg.Sources, m.Source, retention)
+		if err != nil {
+			cancel()
+			return sources, rebuilt, fmt.Errorf("source %q: %w", m.Source, err)
+		}
+		sources[m.Source] = &source{settings: settings, source: src, cancel: cancel}
+		rebuilt[m.Source] = true
+	}
+
+	return sources, rebuilt, nil
+}
+
+func (s *Supervisor) run(m *monitor) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	m.status.mu.Lock()
+	m.status.Name = m.spec.Name
+	m.status.Source = m.spec.Source
+	m.status.Query = m.spec.Query
+	m.status.TimeInterval = m.aggCfg.TimeInterval
+	m.status.StartedAt = time.Now()
+	m.status.NextRun = time.Time{}
+	aggCfg := m.aggCfg
+	if m.status.LastWindow != nil {
+		aggCfg.LastWindowEnd = m.status.LastWindow.To
+	}
+	m.status.mu.Unlock()
+
+	aggCfg.Trigger = m.trigger
+	aggCfg.Observer = m.status
+
+	log.Info().
+		Str("monitor", m.spec.Name).
+		Str("source", m.spec.Source).
+		Dur("timeInterval", m.aggCfg.TimeInterval).
+		Dur("historicalTimeInterval", m.aggCfg.HistoricalTimeInterval).
+		Str("schedule", m.spec.Schedule).
+		Dur("ingestionDelay", m.aggCfg.IngestionDelay).
+		Str("logSeverity", m.aggCfg.LogSeverity).
+		Str("query", m.aggCfg.Query).
+		Strs("dimensions", m.aggCfg.Dimensions).
+		Msg("Monitor configured")
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(m.done)
+		// The loop keeps ingesting while a result is delivered and buffers
+		// what it produces; once stopped, it closes the channel after the
+		// buffered results, so they are still delivered.
+		for result := range aggregator.RunPeriodicAggregation(ctx, aggCfg) {
+			s.deliver(m, result)
+		}
+	}()
+}
+
+func (s *Supervisor) seedSchema(m *monitor) {
+	if s.State == nil {
+		return
+	}
+	last, ok, err := state.Latest(s.State, m.spec.Name)
+	if err != nil {
+		log.Warn().Err(err).Str("monitor", m.spec.Name).Msg("Failed to read stored state")
+		return
+	}
+	if !ok || last.Source != m.spec.Source || last.Query != m.spec.Query ||
+		!slices.Equal(last.Dimensions, m.spec.Dimensions) || len(last.Schema.Fields) == 0 {
+		return
+	}
+	m.aggCfg.SchemaCache.Seed(last.Schema)
+	log.Info().
+		Str("monitor", m.spec.Name).
+		Int("schemaFields", len(last.Schema.Fields)).
+		Time("windowEnd", last.Window.To).
+		Msg("Restored schema from stored state")
+}
+
+// carryOver moves warm state from a stopped loop to its replacement when it
+// still describes the same logs: the schema cache survives unless the logs or
+// schema settings changed, and the baseline additionally needs the same
+// severity filter and windows.
+func carryOver(old *monitor, oldCfg config.Config, m *monitor, cfg config.Config, sourceRebuilt bool) {
+	sameLogs := !sourceRebuilt && old.spec.Source == m.spec.Source && old.spec.Query == m.spec.Query
+	if sameLogs && oldCfg.Schema == cfg.Schema {
+		m.aggCfg.SchemaCache = old.aggCfg.SchemaCache
+	}
+
+	prev, next := old.aggCfg.Baseline, m.aggCfg.Baseline
+	if prev == nil || next == nil {
+		return
+	}
+	sameFile := oldCfg.Historical.BaselinePath == cfg.Historical.BaselinePath
+	if sameLogs && sameFile &&
+		old.spec.Severity == m.spec.Severity &&
+		old.aggCfg.TimeInterval == m.aggCfg.TimeInterval &&
+		old.aggCfg.HistoricalTimeInterval == m.aggCfg.HistoricalTimeInterval {
+		m.aggCfg.Baseline = prev
+		return
+	}
+	if sameFile {
+		// The snapshot on disk was built from different logs or filters.
+		next.Reset()
+	}
+}
+
+func sameLoop(a, b config.Monitor) bool {
+	a.Destinations, b.Destinations = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func settingsOf(cfg config.Config) loopSettings {
+	return loopSettings{
+		IngestionDelay: cfg.IngestionDelay,
+		Historical:     cfg.Historical,
+		Schema:         cfg.Schema,
+		Fuzzy:          cfg.Fuzzy,
+	}
+}
+
+func sourceSettings(sources config.SourcesConfig, kind string) any {
+	switch kind {
+	case "datadog":
+		return sources.DataDog
+	case "file":
+		return sources.File
+	case "loki":
+		return sources.Loki
+	case ingestor.FlavorElasticsearch, ingestor.FlavorOpenSearch:
+		return sources.Elasticsearch
+	case "otlp":
+		return sources.OTLP
+	case "syslog":
+		return sources.Syslog
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,499 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/schema"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
+)
+
+type stubSource struct{}
+
+func (stubSource) Fetch(ctx context.Context, tr ingestor.TimeRange, query string) ([]ingestor.LogRecord, error) {
+	return nil, nil
+}
+
+type stubFactory struct {
+	mu     sync.Mutex
+	builds map[string]int
+	fail   bool
+}
+
+func (f *stubFactory) build(ctx context.Context, sources config.SourcesConfig, kind string, retention time.Duration) (ingestor.LogSource, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, errors.New("unavailable")
+	}
+	if f.builds == nil {
+		f.builds = make(map[string]int)
+	}
+	f.builds[kind]++
+	return &stubSource{}, nil
+}
+
+func (f *stubFactory) count(kind string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.builds[kind]
+}
+
+func testConfig() config.Config {
+	cfg := config.Defaults()
+	cfg.Slack.ChannelID = "C0"
+	cfg.Monitors = []config.Monitor{
+		{Name: "api", Query: "service:api"},
+		{Name: "web", Query: "service:web"},
+	}
+	return cfg
+}
+
+func startSupervisor(t *testing.T, cfg config.Config) (*Supervisor, *stubFactory) {
+	t.Helper()
+	factory := &stubFactory{}
+	handle := func(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error) {
+		return nil, nil
+	}
+	s := New(factory.build, handle)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	if err := s.Start(ctx, cfg); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return s, factory
+}
+
+func TestReload_DestinationOnlyKeepsLoop(t *testing.T) {
+	cfg := testConfig()
+	s, factory := startSupervisor(t, cfg)
+	api, web := s.monitors["api"], s.monitors["web"]
+
+	cfg.Monitors[0].Destinations = []config.Destination{{Type: config.DestinationSlack, Channel: "C1"}}
+	cfg.Anthropic.Model = "another-model"
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if s.monitors["api"] != api || s.monitors["web"] != web {
+		t.Error("loops should keep running when only delivery settings change")
+	}
+	if d := api.delivery.Load(); d.Slack[0].ChannelID != "C1" || d.Analyzer.Model != "another-model" {
+		t.Errorf("delivery not updated: %+v", d)
+	}
+	if n := factory.count("datadog"); n != 1 {
+		t.Errorf("source built %d times, want 1", n)
+	}
+}
+
+func TestReload_RestartsOnlyChangedMonitors(t *testing.T) {
+	cfg := testConfig()
+	s, _ := startSupervisor(t, cfg)
+	api, web := s.monitors["api"], s.monitors["web"]
+
+	cfg.Monitors[0].Query = "service:api env:prod"
+	cfg.Monitors = append(cfg.Monitors[:1], config.Monitor{Name: "jobs", Query: "service:jobs"})
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if s.monitors["api"] == api {
+		t.Error("api should restart after its query changed")
+	}
+	if s.monitors["api"].aggCfg.SchemaCache == api.aggCfg.SchemaCache {
+		t.Error("schema cache should not survive a query change")
+	}
+	if _, ok := s.monitors["web"]; ok {
+		t.Error("web should be removed")
+	}
+	select {
+	case <-web.done:
+	default:
+		t.Error("removed monitor loop should be stopped")
+	}
+	if _, ok := s.monitors["jobs"]; !ok {
+		t.Error("jobs should be started")
+	}
+}
+
+func TestReload_KeepsWarmState(t *testing.T) {
+	cfg := testConfig()
+	cfg.Historical.Baseline = true
+	s, _ := startSupervisor(t, cfg)
+	api, web := s.monitors["api"], s.monitors["web"]
+
+	cfg.Fuzzy.SimilarityThreshold = 0.9
+	cfg.Monitors[1].Severity = "SEVERE"
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	next := s.monitors["api"]
+	if next == api || next.aggCfg.SimilarityThreshold != 0.9 {
+		t.Fatal("api should restart with the new threshold")
+	}
+	if next.aggCfg.SchemaCache != api.aggCfg.SchemaCache || next.aggCfg.Baseline != api.aggCfg.Baseline {
+		t.Error("schema cache and baseline should be carried over")
+	}
+	if s.monitors["web"].aggCfg.Baseline == web.aggCfg.Baseline {
+		t.Error("baseline should be rebuilt after a severity change")
+	}
+	if s.monitors["web"].aggCfg.SchemaCache != web.aggCfg.SchemaCache {
+		t.Error("schema cache should survive a severity change")
+	}
+}
+
+func TestReload_SourceSettingsRebuildSource(t *testing.T) {
+	cfg := testConfig()
+	s, factory := startSupervisor(t, cfg)
+	api := s.monitors["api"]
+
+	cfg.Sources.DataDog.Site = "datadoghq.eu"
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if n := factory.count("datadog"); n != 2 {
+		t.Errorf("source built %d times, want 2", n)
+	}
+	if s.monitors["api"] == api || s.monitors["api"].aggCfg.SchemaCache == api.aggCfg.SchemaCache {
+		t.Error("monitors should restart with fresh state on a rebuilt source")
+	}
+}
+
+func TestReload_FailureKeepsCurrentState(t *testing.T) {
+	cfg := testConfig()
+	s, factory := startSupervisor(t, cfg)
+	api := s.monitors["api"]
+
+	factory.fail = true
+	cfg.Monitors[0].Source = "loki"
+	if err := s.Reload(cfg); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if s.monitors["api"] != api || s.monitors["api"].spec.Source != "datadog" {
+		t.Error("a failed reload should leave monitors untouched")
+	}
+	select {
+	case <-api.done:
+		t.Error("loop should still be running")
+	default:
+	}
+}
+
+func TestStart_SeedsSchemaFromState(t *testing.T) {
+	store := state.NewMemoryStore(state.Retention{})
+	stored := schema.Schema{Fields: []schema.Field{{Name: "service", Type: "string"}}}
+	for _, c := range []state.Cycle{
+		{Monitor: "api", Source: "datadog", Query: "service:api", Schema: stored},
+		{Monitor: "web", Source: "datadog", Query: "service:web-old", Schema: stored},
+	} {
+		if err := store.Append(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := New((&stubFactory{}).build, func(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error) {
+		return nil, nil
+	})
+	s.State = store
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Start(ctx, testConfig()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	s.Wait()
+
+	if got := s.monitors["api"].aggCfg.SchemaCache.Current(); got == nil || !got.HasField("service") {
+		t.Errorf("api schema should be restored, got %v", got)
+	}
+	if got := s.monitors["web"].aggCfg.SchemaCache.Current(); got != nil && got.HasField("service") {
+		t.Errorf("web schema was stored for another query and should not be restored, got %v", got)
+	}
+}
+
+func TestTrigger_RunsCycleAndUpdatesStatus(t *testing.T) {
+	results := make(chan string, 10)
+	s := New((&stubFactory{}).build, func(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error) {
+		results <- result.Monitor
+		return &analyzer.AnalysisResult{Severity: "low"}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	if err := s.Start(ctx, testConfig()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	wait := func(monitors ...string) {
+		t.Helper()
+		pending := make(map[string]bool)
+		for _, m := range monitors {
+			pending[m] = true
+		}
+		timeout := time.After(5 * time.Second)
+		for len(pending) > 0 {
+			select {
+			case m := <-results:
+				delete(pending, m)
+			case <-timeout:
+				t.Fatalf("no cycle for %v", pending)
+			}
+		}
+	}
+	wait("api", "web")
+
+	names, err := s.Trigger("api")
+	if err != nil || len(names) != 1 || names[0] != "api" {
+		t.Fatalf("Trigger: got %v, %v", names, err)
+	}
+	wait("api")
+	if _, err := s.Trigger("jobs"); !errors.Is(err, ErrUnknownMonitor) {
+		t.Errorf("Trigger unknown: got %v", err)
+	}
+
+	statuses := s.Status()
+	if len(statuses) != 2 || statuses[0].Name != "api" || statuses[1].Name != "web" {
+		t.Fatalf("Status: got %+v", statuses)
+	}
+	api := statuses[0]
+	if api.LastSuccess.IsZero() || api.NextRun.IsZero() || api.LastAnalysis == nil || api.LastWindow == nil {
+		t.Errorf("api status not updated: %+v", api)
+	}
+}
+
+func TestStatus_FailedDeliveryIsNotSuccess(t *testing.T) {
+	s := New((&stubFactory{}).build, func(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error) {
+		return nil, errors.New("slack unavailable")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	cfg := testConfig()
+	cfg.Monitors = cfg.Monitors[:1]
+	if err := s.Start(ctx, cfg); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	failures := func(want int) MonitorStatus {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			st := s.Status()[0]
+			if st.ConsecutiveFailures >= want {
+				return st
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %d failures, got %+v", want, st)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	failures(1)
+	if _, err := s.Trigger("api"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	st := failures(2)
+	if !st.LastSuccess.IsZero() || st.LastError == "" || st.LastWindow == nil {
+		t.Errorf("failed deliveries: got %+v", st)
+	}
+}
+
+func TestReload_DoesNotRerunDeliveredWindow(t *testing.T) {
+	var mu sync.Mutex
+	deliveries := make(map[time.Time]int)
+	delivered := make(chan struct{}, 10)
+	s := New((&stubFactory{}).build, func(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error) {
+		mu.Lock()
+		deliveries[result.Window.To]++
+		mu.Unlock()
+		delivered <- struct{}{}
+		return nil, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	cfg := testConfig()
+	cfg.Monitors = cfg.Monitors[:1]
+	if err := s.Start(ctx, cfg); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("startup cycle was not delivered")
+	}
+
+	for _, severity := range []string{"SEVERE", "ALL"} {
+		api := s.monitors["api"]
+		cfg.Monitors[0].Severity = severity
+		if err := s.Reload(cfg); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+		if s.monitors["api"] == api {
+			t.Fatal("api should restart after its severity changed")
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for end, n := range deliveries {
+		if n != 1 {
+			t.Errorf("window ending %v: delivered %d times, want once", end, n)
+		}
+	}
+}
+
+// blockingHandler holds api's deliveries until release is closed and
+// reports each monitor it is called for on entered.
+type blockingHandler struct {
+	entered chan string
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{entered: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) handle(ctx context.Context, result aggregator.AggregationResult, delivery Delivery) (*analyzer.AnalysisResult, error) {
+	h.entered <- result.Monitor
+	if result.Monitor != "api" {
+		return nil, nil
+	}
+	select {
+	case <-h.release:
+		return &analyzer.AnalysisResult{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (h *blockingHandler) wait(t *testing.T, monitor string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-h.entered:
+			if m == monitor {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%s was not delivered", monitor)
+		}
+	}
+}
+
+func TestShutdown_DrainsDeliveriesInFlight(t *testing.T) {
+	h := newBlockingHandler()
+	s := New((&stubFactory{}).build, h.handle)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.Start(ctx, testConfig()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	h.wait(t, "api")
+
+	cancel()
+	time.AfterFunc(20*time.Millisecond, func() { close(h.release) })
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+
+	if undelivered := s.Shutdown(shutdownCtx); len(undelivered) != 0 {
+		t.Errorf("undelivered: got %d results, want none", len(undelivered))
+	}
+	if st := s.Status()[0]; st.LastSuccess.IsZero() || st.LastError != "" {
+		t.Errorf("api delivery should complete after the loops stop, got %+v", st)
+	}
+}
+
+func TestShutdown_ReturnsUndeliveredAfterGracePeriod(t *testing.T) {
+	h := newBlockingHandler()
+	s := New((&stubFactory{}).build, h.handle)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.Start(ctx, testConfig()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	h.wait(t, "api")
+
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShutdown()
+
+	undelivered := s.Shutdown(shutdownCtx)
+	if len(undelivered) != 1 || undelivered[0].Monitor != "api" {
+		t.Fatalf("undelivered: got %+v, want api's result", undelivered)
+	}
+	if st := s.Status()[0]; st.LastError != "" {
+		t.Errorf("a delivery cut off by shutdown should not count as a failure, got %q", st.LastError)
+	}
+	if handled, err := s.Redeliver(undelivered[0]); handled || err != nil {
+		t.Errorf("Redeliver after shutdown: got %v, %v", handled, err)
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	h := newBlockingHandler()
+	close(h.release)
+	s := New((&stubFactory{}).build, h.handle)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	if err := s.Start(ctx, testConfig()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	h.wait(t, "api")
+
+	result := aggregator.AggregationResult{Monitor: "api", LogCount: 7}
+	if handled, err := s.Redeliver(result); !handled || err != nil {
+		t.Fatalf("Redeliver: got %v, %v", handled, err)
+	}
+	h.wait(t, "api")
+
+	if _, err := s.Redeliver(aggregator.AggregationResult{Monitor: "jobs"}); !errors.Is(err, ErrUnknownMonitor) {
+		t.Errorf("Redeliver unknown: got %v", err)
+	}
+}
+
+func TestMonitorStatus_Ready(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	interval := 15 * time.Minute
+
+	tests := []struct {
+		name string
+		st   MonitorStatus
+		want bool
+	}{
+		{name: "starting", st: MonitorStatus{StartedAt: now.Add(-time.Minute)}, want: true},
+		{name: "never succeeded", st: MonitorStatus{StartedAt: now.Add(-time.Hour)}, want: false},
+		{name: "on schedule", st: MonitorStatus{StartedAt: now.Add(-time.Hour), LastSuccess: now.Add(-10 * time.Minute), NextRun: now.Add(5 * time.Minute)}, want: true},
+		{name: "idle schedule", st: MonitorStatus{StartedAt: now.Add(-72 * time.Hour), LastSuccess: now.Add(-60 * time.Hour), NextRun: now.Add(time.Hour)}, want: true},
+		{name: "overdue", st: MonitorStatus{StartedAt: now.Add(-time.Hour), LastSuccess: now.Add(-time.Hour), NextRun: now.Add(-50 * time.Minute)}, want: false},
+		{name: "failing", st: MonitorStatus{StartedAt: now.Add(-time.Hour), LastSuccess: now.Add(-time.Hour), NextRun: now.Add(time.Minute), ConsecutiveFailures: 3}, want: false},
+	}
+	for _, tt := range tests {
+		tt.st.TimeInterval = interval
+		if got := tt.st.Ready(now, 3); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
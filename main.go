@@ -2,147 +2,434 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
-	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
-	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/admin"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
 	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
-	"github.com/ricardonunez-io/lumberjack/internal/schema"
-	slackpkg "github.com/ricardonunez-io/lumberjack/internal/slack"
+	"github.com/ricardonunez-io/lumberjack/internal/leader"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
+const (
+	exitOK      = 0
+	exitAlert   = 1
+	exitError   = 2
+	exitDropped = 3
+)
+
+const usage = `Usage: lumberjack <command> [flags]
+
+Commands:
+  run                        Run every monitor on its schedule (default)
+  once                       Run one cycle per monitor, exit 1 if any alerted
+  backfill --from --to       Evaluate every past window in a time range
+  replay <results.json|dir>  Rerun analysis on saved results, or rerun
+                             recorded fixtures without network access
+  schema                     Print the fields discovered for a monitor's query
+  config validate            Check the configuration and exit
+
+run, once and backfill take --record <dir> to save each cycle's source
+calls, aggregation result and analysis as a fixture for replay.
+
+Errors exit with status 2. run exits with status 3 when it stops with
+results it could neither deliver within shutdown.gracePeriod nor save to the
+outbox. Run "lumberjack <command> -h" for its flags.
+`
+
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Info().Msg("Starting Lumberjack")
+	os.Exit(runCommand(os.Args[1:]))
+}
 
-	ddApiKey := os.Getenv("DD_API_KEY")
-	ddAppKey := os.Getenv("DD_APPLICATION_KEY")
-	if ddApiKey == "" || ddAppKey == "" {
-		log.Fatal().Msg("DD_API_KEY and DD_APPLICATION_KEY are required")
+func runCommand(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	anthropicKey := os.Getenv("ANTHROPIC_API_KEY")
-	if anthropicKey == "" {
-		log.Fatal().Msg("ANTHROPIC_API_KEY is required")
+	switch name {
+	case "run":
+		return runDaemon(args)
+	case "once":
+		return runOnce(args)
+	case "backfill":
+		return runBackfill(args)
+	case "replay":
+		return runReplay(args)
+	case "schema":
+		return runSchema(args)
+	case "config":
+		if len(args) > 0 && args[0] == "validate" {
+			return validateConfig(args[1:])
+		}
+	case "help":
+		fmt.Print(usage)
+		return exitOK
 	}
 
-	slackConfig := slackpkg.Config{
-		BotToken:  os.Getenv("SLACK_BOT_TOKEN"),
-		ChannelID: os.Getenv("SLACK_CHANNEL_ID"),
-	}
-	if slackConfig.BotToken == "" || slackConfig.ChannelID == "" {
-		log.Fatal().Msg("SLACK_BOT_TOKEN and SLACK_CHANNEL_ID are required")
-	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", strings.Join(append([]string{name}, args...), " "), usage)
+	return exitError
+}
 
-	analyzerConfig := analyzer.DefaultConfig(anthropicKey)
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("LUMBERJACK_CONFIG"), "path to a YAML, TOML or JSON config file")
+	return fs, configPath
+}
 
-	logSeverity := os.Getenv("LOG_SEVERITY")
-	if !aggregator.ValidLogSeverities.Includes(logSeverity) {
-		log.Warn().Str("value", logSeverity).Msg("Invalid LOG_SEVERITY, defaulting to MEDIUM")
-		logSeverity = "MEDIUM"
+func runDaemon(args []string) int {
+	fs, configPath := newFlagSet("run")
+	watch := fs.Duration("watch", 0, "poll the config and monitors files at this interval and reload on change (0 disables)")
+	notify := addNotifyFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitError
 	}
 
-	query := os.Getenv("DD_QUERY")
-	if query == "" {
-		log.Info().Msg("No DD_QUERY set, defaulting to empty query (all logs)")
-		query = "*"
+	n, closeOutput, err := notify.notifier()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
 	}
+	defer closeOutput()
 
-	timeIntervalKey := os.Getenv("TIME_INTERVAL")
-	if !ingestor.ValidTimeIntervals.Includes(timeIntervalKey) {
-		log.Warn().Str("value", timeIntervalKey).Msg("Invalid TIME_INTERVAL, defaulting to FIFTEEN_MINUTES")
-		timeIntervalKey = "FIFTEEN_MINUTES"
-	}
-	timeInterval := ingestor.TimeIntervalToDurationMapping[timeIntervalKey]
+	log.Info().Msg("Starting Lumberjack")
 
-	historicalTimeIntervalKey := os.Getenv("HISTORICAL_TIME_INTERVAL")
-	if !ingestor.ValidTimeIntervals.Includes(historicalTimeIntervalKey) {
-		log.Warn().Str("value", historicalTimeIntervalKey).Msg("Invalid HISTORICAL_TIME_INTERVAL, defaulting to ONE_DAY")
-		historicalTimeIntervalKey = "ONE_DAY"
+	cfg, err := config.LoadWith(*configPath, notify.options())
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid configuration")
+		return exitError
 	}
 
-	if ingestor.TimeIntervalToDurationMapping[historicalTimeIntervalKey] <= timeInterval {
-		log.Warn().Msg("HISTORICAL_TIME_INTERVAL must be greater than TIME_INTERVAL, defaulting to ONE_DAY")
-		historicalTimeIntervalKey = "ONE_DAY"
+	// State settings are read once; a reload doesn't move the store. With
+	// leader election each term opens it instead.
+	if cfg.Leader.Backend == leader.BackendNone {
+		store, err := openState(cfg.State)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to open state store")
+			return exitError
+		}
+		n.state = store
 	}
 
-	log.Info().
-		Str("timeInterval", timeIntervalKey).
-		Str("historicalTimeInterval", historicalTimeIntervalKey).
-		Str("logSeverity", logSeverity).
-		Str("query", query).
-		Msg("Configuration loaded")
+	// So are shutdown settings.
+	grace, err := time.ParseDuration(cfg.Shutdown.GracePeriod)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid shutdown grace period")
+		return exitError
+	}
+	ob, err := openOutbox(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open outbox")
+		return exitError
+	}
 
-	ddClient := ingestor.InitializeDataDog()
-	schemaCache := schema.NewCache(10)
+	// And leader election.
+	elector, err := newElector(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid leader election settings")
+		return exitError
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	force := make(chan struct{})
+	d := &daemon{ctx: ctx, n: n, outbox: ob, grace: grace, force: force, cfg: cfg}
+	if elector != nil {
+		d.stateCfg = &cfg.State
+	}
+
+	// Like state, the admin server is configured once at startup.
+	var adminFailed atomic.Bool
+	if cfg.Admin.ListenAddr != "" {
+		server := admin.New(d, cfg.Admin.ReadyIntervals)
+		if elector != nil {
+			server.Role = d.role
+		}
+		// A failure to serve stops the daemon as a signal would, with
+		// exitError.
+		go func() {
+			if err := server.ListenAndServe(ctx, cfg.Admin.ListenAddr); err != nil {
+				log.Error().Err(err).Msg("Admin server failed")
+				adminFailed.Store(true)
+				cancel()
+			}
+		}()
+	}
+
+	reload := make(chan struct{}, 1)
 	go func() {
 		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-		sig := <-sigChan
-		log.Info().Str("signal", sig.String()).Msg("Received shutdown signal")
-		cancel()
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		stopping := false
+		for sig := range sigChan {
+			switch {
+			case sig == syscall.SIGHUP:
+				log.Info().Msg("Received SIGHUP, reloading configuration")
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+			case stopping:
+				log.Warn().Str("signal", sig.String()).Msg("Received second shutdown signal, skipping the grace period")
+				close(force)
+				return
+			default:
+				log.Info().Str("signal", sig.String()).Msg("Received shutdown signal")
+				stopping = true
+				cancel()
+			}
+		}
 	}()
 
-	aggCfg := aggregator.AggregationConfig{
-		Client:                    ddClient,
-		TimeInterval:              timeInterval,
-		Query:                     query,
-		LogSeverity:               logSeverity,
-		TimeIntervalKey:           timeIntervalKey,
-		HistoricalTimeIntervalKey: historicalTimeIntervalKey,
-		SchemaCache:               schemaCache,
+	var changes <-chan struct{}
+	if *watch > 0 {
+		changes = config.Watch(ctx, *watch, *configPath, os.Getenv("MONITORS_FILE"))
 	}
 
-	resultChan := aggregator.RunPeriodicAggregation(ctx, aggCfg)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload:
+			case <-changes:
+			}
 
-	for result := range resultChan {
-		if err := processResult(ctx, result, slackConfig, analyzerConfig); err != nil {
-			log.Err(err).Msg("Error processing aggregation result")
+			next, err := config.LoadWith(*configPath, notify.options())
+			if err != nil {
+				log.Err(err).Msg("Reloaded configuration is invalid, keeping the current one")
+				continue
+			}
+			if err := d.reload(next); err != nil {
+				log.Err(err).Msg("Failed to apply reloaded configuration, keeping the current one")
+			}
+		}
+	}()
+
+	if elector != nil {
+		elector.Run(ctx, d.lead)
+	} else {
+		metrics.Leader.Set(1)
+		if err := d.lead(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to start")
+			return exitError
 		}
 	}
 
+	if adminFailed.Load() {
+		return exitError
+	}
+	if d.dropped > 0 {
+		log.Error().Int("dropped", d.dropped).Msg("Lumberjack stopped with undelivered results")
+		return exitDropped
+	}
 	log.Info().Msg("Lumberjack stopped")
+	return exitOK
 }
 
-func processResult(ctx context.Context, result aggregator.AggregationResult, slackCfg slackpkg.Config, analyzerCfg analyzer.Config) error {
-	data, err := json.Marshal(result)
-	if err != nil {
-		return fmt.Errorf("failed to marshal aggregation result: %w", err)
+func validateConfig(args []string) int {
+	fs, configPath := newFlagSet("config validate")
+	dryRun := fs.Bool("dry-run", false, "check the configuration for running with --dry-run, which posts nothing")
+	if err := fs.Parse(args); err != nil {
+		return exitError
 	}
 
-	analysis, err := analyzer.Analyze(ctx, string(data), analyzerCfg)
+	cfg, err := config.LoadWith(*configPath, config.Options{DryRun: *dryRun})
 	if err != nil {
-		return fmt.Errorf("analyzer error: %w", err)
+		fmt.Fprintf(os.Stderr, "configuration is invalid:\n%v\n", err)
+		return exitError
+	}
+
+	monitors := cfg.ResolvedMonitors()
+	fmt.Printf("configuration is valid: %d monitor(s)\n", len(monitors))
+	for _, m := range monitors {
+		fmt.Printf("  %s: source=%s severity=%s interval=%s historical=%s\n", m.Name, m.Source, m.Severity, m.TimeInterval, m.HistoricalTimeInterval)
+	}
+	return exitOK
+}
+
+func buildLogSource(ctx context.Context, sources config.SourcesConfig, sourceType string, retention time.Duration) (ingestor.LogSource, error) {
+	switch sourceType {
+	case "datadog":
+		return buildDataDogSource(sources.DataDog)
+	case "file":
+		return buildFileSource(sources.File)
+	case "loki":
+		return buildLokiSource(sources.Loki), nil
+	case ingestor.FlavorElasticsearch, ingestor.FlavorOpenSearch:
+		return buildElasticsearchSource(sources.Elasticsearch, sourceType), nil
+	case "otlp":
+		return buildOTLPSource(ctx, sources.OTLP, retention)
+	case "syslog":
+		return buildSyslogSource(ctx, sources.Syslog, retention)
+	default:
+		return nil, fmt.Errorf("unknown log source %q", sourceType)
+	}
+}
+
+func buildDataDogSource(c config.DataDogConfig) (ingestor.LogSource, error) {
+	dd := ingestor.NewDataDogSource(ingestor.InitializeDataDog())
+	dd.APIKey = c.APIKey
+	dd.ApplicationKey = c.ApplicationKey
+	dd.Site = c.Site
+	dd.PageLimit = int32(c.PageLimit)
+	dd.MaxLogs = c.MaxLogs
+	dd.MaxPages = c.MaxPages
+
+	if c.Retry.MaxAttempts > 0 {
+		dd.Retry.MaxAttempts = c.Retry.MaxAttempts
+	}
+	backoffs := []struct {
+		value   string
+		backoff *time.Duration
+	}{
+		{c.Retry.InitialBackoff, &dd.Retry.InitialBackoff},
+		{c.Retry.MaxBackoff, &dd.Retry.MaxBackoff},
+	}
+	for _, b := range backoffs {
+		if b.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(b.value)
+		if err != nil {
+			return nil, err
+		}
+		*b.backoff = d
+	}
+
+	return dd, nil
+}
+
+func buildFileSource(c config.FileConfig) (ingestor.LogSource, error) {
+	fs := ingestor.NewFileSource(c.Path, c.Format)
+	fs.TimestampLayout = c.TimestampLayout
+	if c.TimestampField != "" {
+		fs.Fields.Timestamp = c.TimestampField
+	}
+
+	if c.Format == ingestor.FileFormatText {
+		parser, err := ingestor.NewRegexLineParser(c.LinePattern, fs.Fields, fs.TimestampLayout)
+		if err != nil {
+			return nil, err
+		}
+		fs.LineParser = parser
+	}
+
+	return fs, nil
+}
+
+func buildLokiSource(c config.LokiConfig) ingestor.LogSource {
+	ls := ingestor.NewLokiSource(c.URL)
+	ls.TenantID = c.TenantID
+	ls.Username = c.Username
+	ls.Password = c.Password
+	if c.PageLimit > 0 {
+		ls.PageLimit = c.PageLimit
 	}
+	return ls
+}
 
-	if !analysis.SendSummary {
-		log.Info().
-			Int("signalStrength", analysis.SignalStrength).
-			Str("severity", analysis.Severity).
-			Msg("Analysis below alert threshold, skipping Slack notification")
-		return nil
+func buildElasticsearchSource(c config.ElasticsearchConfig, flavor string) ingestor.LogSource {
+	es := ingestor.NewElasticsearchSource(c.URL, c.Index, flavor)
+	es.Username = c.Username
+	es.Password = c.Password
+	es.APIKey = c.APIKey
+	if c.PageSize > 0 {
+		es.PageSize = c.PageSize
+	}
+
+	fieldOverrides := []struct{ value, field *string }{
+		{&c.TimestampField, &es.Fields.Timestamp},
+		{&c.MessageField, &es.Fields.Message},
+		{&c.StatusField, &es.Fields.Status},
+		{&c.HostField, &es.Fields.Host},
+		{&c.ServiceField, &es.Fields.Service},
+	}
+	for _, o := range fieldOverrides {
+		if *o.value != "" {
+			*o.field = *o.value
+		}
 	}
 
-	log.Info().
-		Int("signalStrength", analysis.SignalStrength).
-		Str("severity", analysis.Severity).
-		Msg("Sending analysis to Slack")
+	return es
+}
+
+func buildOTLPSource(ctx context.Context, c config.OTLPConfig, retention time.Duration) (ingestor.LogSource, error) {
+	return receivers.acquire(ctx, "otlp "+c.ListenAddr, func(ctx context.Context, serve func(string, func() error)) (ingestor.LogSource, error) {
+		ln, err := net.Listen("tcp", c.ListenAddr)
+		if err != nil {
+			return nil, err
+		}
+		receiver := ingestor.NewOTLPReceiver(newBuffer(c.BufferSize, retention))
+		serve("otlp", func() error { return receiver.Serve(ctx, ln) })
+		return receiver, nil
+	})
+}
+
+func buildSyslogSource(ctx context.Context, c config.SyslogConfig, retention time.Duration) (ingestor.LogSource, error) {
+	udpAddr, tcpAddr := c.Addrs()
+	key := fmt.Sprintf("syslog udp=%s tcp=%s", udpAddr, tcpAddr)
+	return receivers.acquire(ctx, key, func(ctx context.Context, serve func(string, func() error)) (ingestor.LogSource, error) {
+		var conn net.PacketConn
+		var ln net.Listener
+		var err error
+		if udpAddr != "" {
+			if conn, err = net.ListenPacket("udp", udpAddr); err != nil {
+				return nil, err
+			}
+		}
+		if tcpAddr != "" {
+			if ln, err = net.Listen("tcp", tcpAddr); err != nil {
+				if conn != nil {
+					conn.Close()
+				}
+				return nil, err
+			}
+		}
+
+		receiver := ingestor.NewSyslogReceiver(newBuffer(c.BufferSize, retention))
+		if conn != nil {
+			log.Info().Str("addr", conn.LocalAddr().String()).Msg("Starting syslog UDP listener")
+			serve("syslog udp", func() error { return receiver.ServeUDP(ctx, conn) })
+		}
+		if ln != nil {
+			log.Info().Str("addr", ln.Addr().String()).Msg("Starting syslog TCP listener")
+			serve("syslog tcp", func() error { return receiver.ServeTCP(ctx, ln) })
+		}
+		return receiver, nil
+	})
+}
 
-	if err := slackpkg.SendMessage(*analysis, slackCfg); err != nil {
-		return fmt.Errorf("slack error: %w", err)
+func openState(cfg config.StateConfig) (state.Store, error) {
+	retention := state.Retention{MaxCycles: cfg.MaxCycles}
+	if cfg.MaxAge != "" {
+		maxAge, err := time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		retention.MaxAge = maxAge
 	}
+	return state.Open(cfg.Backend, cfg.Path, retention)
+}
 
-	return nil
+func newBuffer(size int, retention time.Duration) *ingestor.MemoryBuffer {
+	if size <= 0 {
+		size = ingestor.DefaultBufferCapacity
+	}
+	return ingestor.NewMemoryBuffer(size, retention)
 }
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/analyzer"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/fixture"
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/ricardonunez-io/lumberjack/internal/metrics"
+	slackpkg "github.com/ricardonunez-io/lumberjack/internal/slack"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
+	"github.com/ricardonunez-io/lumberjack/internal/supervisor"
+	"github.com/rs/zerolog/log"
+)
+
+// notifier analyzes results and delivers alerts, or with a dry run writes
+// the rendered payloads as JSON lines instead of posting them. With a
+// recorder, every analyzed cycle is also written out as a fixture, and with
+// a state store every delivered cycle is kept as history.
+type notifier struct {
+	dryRun      io.Writer
+	recorder    *fixture.Recorder
+	state       state.Store
+	runAnalysis func(context.Context, aggregator.AggregationResult, analyzer.Config) (*analyzer.AnalysisResult, error)
+	mu          sync.Mutex
+}
+
+type dryRunRecord struct {
+	Monitor       string                   `json:"monitor"`
+	Window        ingestor.AbsoluteRange   `json:"window"`
+	Analysis      *analyzer.AnalysisResult `json:"analysis"`
+	Notifications []dryRunNotification     `json:"notifications"`
+}
+
+type dryRunNotification struct {
+	Type    string `json:"type"`
+	Payload any    `json:"payload"`
+}
+
+type notifyFlags struct {
+	dryRun *bool
+	output *string
+	record *string
+}
+
+func addNotifyFlags(fs *flag.FlagSet) notifyFlags {
+	return notifyFlags{
+		dryRun: fs.Bool("dry-run", false, "print rendered notifications instead of posting them"),
+		output: fs.String("dry-run-output", "", "write dry-run notifications to this file instead of stdout"),
+		record: fs.String("record", "", "write each cycle's source calls, result and analysis to this directory"),
+	}
+}
+
+// options returns what the configuration must provide for these flags.
+func (f notifyFlags) options() config.Options {
+	return config.Options{DryRun: *f.dryRun}
+}
+
+// notifier returns the notifier selected by the flags and a function that
+// closes any output file it opened.
+func (f notifyFlags) notifier() (*notifier, func(), error) {
+	n := &notifier{runAnalysis: analyzeResult}
+	if *f.record != "" {
+		n.recorder = fixture.NewRecorder(*f.record)
+	}
+
+	if !*f.dryRun {
+		if *f.output != "" {
+			return nil, nil, fmt.Errorf("--dry-run-output requires --dry-run")
+		}
+		return n, func() {}, nil
+	}
+	if *f.output == "" {
+		n.dryRun = os.Stdout
+		return n, func() {}, nil
+	}
+
+	file, err := os.OpenFile(*f.output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	n.dryRun = file
+	return n, func() { file.Close() }, nil
+}
+
+// wrapSource records cfg's source calls when recording is enabled.
+func (n *notifier) wrapSource(cfg aggregator.AggregationConfig, src ingestor.LogSource) ingestor.LogSource {
+	if n.recorder == nil {
+		return src
+	}
+	return n.recorder.Source(cfg, src)
+}
+
+// deliver analyzes result and sends the analysis to every destination when
+// it crosses the alert threshold.
+func (n *notifier) deliver(ctx context.Context, result aggregator.AggregationResult, delivery supervisor.Delivery) (*analyzer.AnalysisResult, error) {
+	analysis, err := n.analyze(ctx, result, delivery.Analyzer)
+	if err != nil {
+		// A delivery cancelled by shutdown is retried from the outbox, which
+		// stores its outcome then.
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			n.save(result, delivery, nil, err, nil)
+		}
+		return nil, err
+	}
+
+	start := time.Now()
+	notifications, err := n.notify(result, analysis, delivery)
+	metrics.ObserveStage(result.Monitor, metrics.StageNotify, start)
+	observeNotifications(result.Monitor, analysis, notifications)
+
+	n.save(result, delivery, analysis, nil, notifications)
+	return analysis, err
+}
+
+func observeNotifications(monitor string, analysis *analyzer.AnalysisResult, notifications []state.Notification) {
+	if !analysis.SendSummary {
+		metrics.NotificationsSuppressed.WithLabelValues(monitor).Inc()
+		return
+	}
+	for _, nt := range notifications {
+		outcome := metrics.OutcomeFailed
+		switch {
+		case nt.DryRun:
+			outcome = metrics.OutcomeDryRun
+		case nt.Sent:
+			outcome = metrics.OutcomeSent
+		}
+		metrics.Notifications.WithLabelValues(monitor, nt.Destination, outcome).Inc()
+	}
+}
+
+func (n *notifier) notify(result aggregator.AggregationResult, analysis *analyzer.AnalysisResult, delivery supervisor.Delivery) ([]state.Notification, error) {
+	if !analysis.SendSummary {
+		log.Info().
+			Str("monitor", result.Monitor).
+			Int("signalStrength", analysis.SignalStrength).
+			Str("severity", analysis.Severity).
+			Msg("Analysis below alert threshold, skipping Slack notification")
+		return nil, n.writeDryRun(result, analysis, nil)
+	}
+
+	var outcomes []state.Notification
+	if n.dryRun != nil {
+		notifications := make([]dryRunNotification, 0, len(delivery.Slack))
+		for _, slackCfg := range delivery.Slack {
+			notifications = append(notifications, dryRunNotification{
+				Type:    config.DestinationSlack,
+				Payload: slackpkg.Render(*analysis, slackCfg),
+			})
+			outcomes = append(outcomes, state.Notification{Destination: config.DestinationSlack, Channel: slackCfg.ChannelID, DryRun: true})
+		}
+		log.Info().
+			Str("monitor", result.Monitor).
+			Int("signalStrength", analysis.SignalStrength).
+			Str("severity", analysis.Severity).
+			Msg("Dry run, writing Slack notification instead of posting")
+		return outcomes, n.writeDryRun(result, analysis, notifications)
+	}
+
+	log.Info().
+		Str("monitor", result.Monitor).
+		Int("signalStrength", analysis.SignalStrength).
+		Str("severity", analysis.Severity).
+		Msg("Sending analysis to Slack")
+
+	// One channel failing doesn't keep the alert from the others.
+	var errs []error
+	for _, slackCfg := range delivery.Slack {
+		outcome := state.Notification{Destination: config.DestinationSlack, Channel: slackCfg.ChannelID}
+		if err := slackpkg.SendMessage(*analysis, slackCfg); err != nil {
+			outcome.Error = err.Error()
+			errs = append(errs, fmt.Errorf("slack error for %s: %w", slackCfg.ChannelID, err))
+		} else {
+			outcome.Sent = true
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	return outcomes, errors.Join(errs...)
+}
+
+// save stores the cycle's outcome when a state store is configured. Failures
+// only cost history, so they are logged rather than failing the cycle.
+func (n *notifier) save(result aggregator.AggregationResult, delivery supervisor.Delivery, analysis *analyzer.AnalysisResult, analysisErr error, notifications []state.Notification) {
+	if n.state == nil {
+		return
+	}
+
+	c := state.NewCycle(result)
+	c.Source = delivery.Monitor.Source
+	c.Query = delivery.Monitor.Query
+	c.Dimensions = delivery.Monitor.Dimensions
+	c.Analysis = analysis
+	c.Notifications = notifications
+	if analysisErr != nil {
+		c.AnalysisError = analysisErr.Error()
+	}
+	if err := n.state.Append(c); err != nil {
+		log.Warn().Err(err).Str("monitor", result.Monitor).Msg("Failed to store cycle state")
+	}
+}
+
+func (n *notifier) writeDryRun(result aggregator.AggregationResult, analysis *analyzer.AnalysisResult, notifications []dryRunNotification) error {
+	if n.dryRun == nil {
+		return nil
+	}
+	if notifications == nil {
+		notifications = []dryRunNotification{}
+	}
+
+	data, err := json.Marshal(dryRunRecord{
+		Monitor:       result.Monitor,
+		Window:        result.Window,
+		Analysis:      analysis,
+		Notifications: notifications,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render dry run: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err = n.dryRun.Write(append(data, '\n'))
+	return err
+}
+
+// handle analyzes result and, with notify, also delivers it like the daemon
+// would.
+func (n *notifier) handle(ctx context.Context, result aggregator.AggregationResult, delivery supervisor.Delivery, notify bool) (*analyzer.AnalysisResult, error) {
+	if notify {
+		return n.deliver(ctx, result, delivery)
+	}
+	return n.analyze(ctx, result, delivery.Analyzer)
+}
+
+// analyze runs the analysis without delivering it, recording the cycle when
+// recording is enabled.
+func (n *notifier) analyze(ctx context.Context, result aggregator.AggregationResult, analyzerCfg analyzer.Config) (*analyzer.AnalysisResult, error) {
+	start := time.Now()
+	analysis, err := n.runAnalysis(metrics.WithMonitor(ctx, result.Monitor), result, analyzerCfg)
+	metrics.ObserveStage(result.Monitor, metrics.StageAnalyze, start)
+	if analysis != nil {
+		metrics.SignalStrength.WithLabelValues(result.Monitor).Observe(float64(analysis.SignalStrength))
+	}
+
+	n.record(result, analysis)
+	return analysis, err
+}
+
+func (n *notifier) record(result aggregator.AggregationResult, analysis *analyzer.AnalysisResult) {
+	if n.recorder == nil {
+		return
+	}
+	path, err := n.recorder.Record(result, analysis)
+	if err != nil {
+		log.Warn().Err(err).Str("monitor", result.Monitor).Msg("Failed to record fixture")
+		return
+	}
+	log.Info().Str("monitor", result.Monitor).Str("path", path).Msg("Recorded fixture")
+}
+
+func analyzeResult(ctx context.Context, result aggregator.AggregationResult, analyzerCfg analyzer.Config) (*analyzer.AnalysisResult, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal aggregation result: %w", err)
+	}
+
+	analysis, err := analyzer.Analyze(ctx, string(data), analyzerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer error: %w", err)
+	}
+	analysis.Monitor = result.Monitor
+	return analysis, nil
+}
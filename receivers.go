@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ricardonunez-io/lumberjack/internal/ingestor"
+	"github.com/rs/zerolog/log"
+)
+
+// receivers shares push receivers by listen address. Sources are built for
+// every leader term, and a receiver the previous term still holds keeps its
+// port, so a new source for the same address reuses it, with the logs it
+// has buffered, instead of binding again. The listeners close once no
+// source holds the receiver.
+var receivers = &receiverSet{open: make(map[string]*sharedReceiver)}
+
+type receiverSet struct {
+	mu   sync.Mutex
+	open map[string]*sharedReceiver
+}
+
+type sharedReceiver struct {
+	source ingestor.LogSource
+	refs   int
+	stop   context.CancelFunc
+	served sync.WaitGroup
+}
+
+// listenFunc binds its listeners before returning, so an address in use is
+// reported to the caller, and hands each one to serve, which runs it until
+// ctx is done.
+type listenFunc func(ctx context.Context, serve func(name string, run func() error)) (ingestor.LogSource, error)
+
+// acquire returns the receiver for key, starting it with listen if none is
+// open, and gives it up when ctx is done.
+func (rs *receiverSet) acquire(ctx context.Context, key string, listen listenFunc) (ingestor.LogSource, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	r, ok := rs.open[key]
+	if !ok {
+		serveCtx, stop := context.WithCancel(context.Background())
+		r = &sharedReceiver{stop: stop}
+		source, err := listen(serveCtx, func(name string, run func() error) {
+			r.served.Add(1)
+			go func() {
+				defer r.served.Done()
+				if err := run(); err != nil {
+					log.Error().Err(err).Str("listener", name).Msg("Receiver stopped")
+				}
+			}()
+		})
+		if err != nil {
+			stop()
+			r.served.Wait()
+			return nil, err
+		}
+		r.source = source
+		rs.open[key] = r
+	}
+
+	r.refs++
+	context.AfterFunc(ctx, func() { rs.release(key, r) })
+	return r.source, nil
+}
+
+// release closes the receiver's listeners once nothing holds it, and waits
+// for them so the port is free when it returns.
+func (rs *receiverSet) release(key string, r *sharedReceiver) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r.refs--
+	if r.refs > 0 {
+		return
+	}
+	delete(rs.open, key)
+	r.stop()
+	r.served.Wait()
+}
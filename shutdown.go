@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/ricardonunez-io/lumberjack/internal/aggregator"
+	"github.com/ricardonunez-io/lumberjack/internal/config"
+	"github.com/ricardonunez-io/lumberjack/internal/outbox"
+	"github.com/ricardonunez-io/lumberjack/internal/state"
+	"github.com/ricardonunez-io/lumberjack/internal/supervisor"
+	"github.com/rs/zerolog/log"
+)
+
+// openOutbox opens shutdown.outboxPath, or an outbox directory beside the
+// file state store when it is unset. Without either there is no outbox and
+// results still undelivered at the end of the grace period are dropped.
+func openOutbox(cfg config.Config) (*outbox.Outbox, error) {
+	dir := cfg.Shutdown.OutboxPath
+	if dir == "" {
+		if cfg.State.Backend != state.BackendFile {
+			return nil, nil
+		}
+		dir = cfg.State.Path
+		if dir == "" {
+			dir = state.DefaultPath()
+		}
+		dir = filepath.Join(dir, "outbox")
+	}
+	return outbox.New(dir)
+}
+
+// redeliver hands the results an earlier run saved at shutdown to their
+// monitors, oldest first, and removes each once it is handled. Entries a
+// shutdown cuts off stay in the outbox for the next run.
+func redeliver(ob *outbox.Outbox, sup *supervisor.Supervisor) {
+	entries, err := ob.List()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read the outbox")
+		return
+	}
+	if len(entries) > 0 {
+		log.Info().Int("results", len(entries)).Msg("Delivering results saved at the last shutdown")
+	}
+
+	for _, e := range entries {
+		handled, err := sup.Redeliver(e.Result)
+		if errors.Is(err, supervisor.ErrUnknownMonitor) {
+			log.Warn().Str("monitor", e.Result.Monitor).Msg("Discarding saved result for a monitor that no longer exists")
+			handled = true
+		}
+		if !handled {
+			return
+		}
+		if err := ob.Remove(e); err != nil {
+			log.Warn().Err(err).Str("monitor", e.Result.Monitor).Msg("Failed to remove delivered result from the outbox")
+		}
+	}
+}
+
+// saveUndelivered puts results in the outbox and returns how many were
+// dropped, because there is no outbox or saving failed.
+func saveUndelivered(ob *outbox.Outbox, results []aggregator.AggregationResult) int {
+	dropped := 0
+	for _, r := range results {
+		if ob == nil {
+			log.Error().Str("monitor", r.Monitor).Time("windowEnd", r.Window.To).Msg("Dropping undelivered result, no outbox is configured")
+			dropped++
+			continue
+		}
+		path, err := ob.Put(r)
+		if err != nil {
+			log.Error().Err(err).Str("monitor", r.Monitor).Time("windowEnd", r.Window.To).Msg("Dropping undelivered result, failed to save it to the outbox")
+			dropped++
+			continue
+		}
+		log.Info().Str("monitor", r.Monitor).Time("windowEnd", r.Window.To).Str("path", path).Msg("Saved undelivered result to the outbox")
+	}
+	return dropped
+}